@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/rizghz/api/helpers"
+	"github.com/rizghz/api/metrics"
+	"github.com/rizghz/api/models"
+	"golang.org/x/sync/singleflight"
+)
+
+// loads run apart from the request that started them, as other requests
+// wait on them, and are bounded by this timeout instead
+const loadTimeout = 30 * time.Second
+
+type Cache struct {
+	store Store
+	ttl   time.Duration
+	group singleflight.Group
+	// generation changes on every invalidation, so loads that raced a write
+	// are not stored
+	generation atomic.Uint64
+}
+
+func New(store Store, ttl time.Duration) *Cache {
+	return &Cache{
+		store: store,
+		ttl:   ttl,
+	}
+}
+
+// fetch returns the value stored under key, or loads it once for all the
+// concurrent callers and stores it. Reads of a sparse fieldset or with
+// relations are never cached.
+func fetch[T any](ctx context.Context, c *Cache, resource string, key string, load func(ctx context.Context) (T, bool)) (T, bool) {
+	if selection := models.GetSelection(ctx); len(selection.Fields) > 0 || len(selection.Include) > 0 {
+		return load(ctx)
+	}
+	var value T
+	data, found, err := c.store.Get(ctx, key)
+	if err != nil {
+		helpers.Log(ctx).Error(err.Error())
+	}
+	if found && json.Unmarshal(data, &value) == nil {
+		metrics.CacheRequests.WithLabelValues(resource, "hit").Inc()
+		return value, true
+	}
+	metrics.CacheRequests.WithLabelValues(resource, "miss").Inc()
+
+	type loaded struct {
+		value T
+		data  []byte
+	}
+	result, err, shared := c.group.Do(key, func() (interface{}, error) {
+		// the first caller going away must not fail the others, and a
+		// lagging replica must not store a row older than the last write
+		ctx, cancel := context.WithTimeout(helpers.WithPrimary(detached{ctx}), loadTimeout)
+		defer cancel()
+		generation := c.generation.Load()
+		value, found := load(ctx)
+		if !found {
+			return nil, errNotLoaded
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			helpers.Log(ctx).Error(err.Error())
+		} else if generation == c.generation.Load() {
+			if err := c.store.Set(ctx, key, data, c.ttl); err != nil {
+				helpers.Log(ctx).Error(err.Error())
+			}
+		}
+		return loaded{value, data}, nil
+	})
+	if err != nil {
+		return value, false
+	}
+	first := result.(loaded)
+	// callers sharing a load get their own copy, as models hand out records
+	// their callers may change
+	if shared && first.data != nil {
+		var copied T
+		if json.Unmarshal(first.data, &copied) == nil {
+			return copied, true
+		}
+	}
+	return first.value, true
+}
+
+// errNotLoaded reports a load that found nothing or failed, the models log
+// their errors themselves
+var errNotLoaded = errors.New("[err]: cache load found nothing")
+
+// detached keeps the values of a context but not its deadline or
+// cancellation
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
+
+// invalidate drops keys after a write
+func (c *Cache) invalidate(ctx context.Context, keys ...string) {
+	c.generation.Add(1)
+	for _, key := range keys {
+		c.group.Forget(key)
+	}
+	if err := c.store.Delete(ctx, keys...); err != nil {
+		helpers.Log(ctx).Error(err.Error())
+	}
+}
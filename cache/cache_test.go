@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rizghz/api/helpers"
+	"github.com/rizghz/api/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// CountingBookMockModel counts the reads reaching the model, release holds
+// them until it is closed when it is set
+type CountingBookMockModel struct {
+	models.IBookModel
+	reads   atomic.Int32
+	release chan struct{}
+	// replica counts the reads that would not go to the primary
+	replica atomic.Int32
+}
+
+func (mock *CountingBookMockModel) Get(ctx context.Context) []models.Book {
+	mock.reads.Add(1)
+	if consistency := helpers.GetConsistency(ctx); consistency == nil || !consistency.Primary() {
+		mock.replica.Add(1)
+	}
+	if mock.release != nil {
+		<-mock.release
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return []models.Book{{Title: "Learning Go"}}
+}
+
+func (mock *CountingBookMockModel) Find(ctx context.Context, key *int) *models.Book {
+	mock.reads.Add(1)
+	if *key != 1 {
+		return nil
+	}
+	book := &models.Book{Title: "Learning Go"}
+	book.ID = 1
+	return book
+}
+
+func (mock *CountingBookMockModel) Create(ctx context.Context, book *models.Book) (bool, *models.Book) {
+	return true, book
+}
+
+func (mock *CountingBookMockModel) Update(ctx context.Context, book *models.Book) (bool, *models.Book) {
+	return true, book
+}
+
+func (mock *CountingBookMockModel) Delete(ctx context.Context, key *int) bool {
+	return true
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Valid Memory Store Eviction", func(t *testing.T) {
+		store := NewMemoryStore(2)
+		store.Set(ctx, "a", []byte("1"), time.Minute)
+		store.Set(ctx, "b", []byte("2"), time.Minute)
+		store.Get(ctx, "a")
+		store.Set(ctx, "c", []byte("3"), time.Minute)
+		_, found, _ := store.Get(ctx, "b")
+		assert.False(t, found, "the least recently used entry is evicted")
+		value, found, _ := store.Get(ctx, "a")
+		assert.True(t, found)
+		assert.Equal(t, []byte("1"), value)
+		assert.Equal(t, 2, store.Len())
+	})
+
+	t.Run("Valid Memory Store Expiry", func(t *testing.T) {
+		store := NewMemoryStore(2)
+		store.Set(ctx, "a", []byte("1"), time.Millisecond)
+		time.Sleep(5 * time.Millisecond)
+		_, found, _ := store.Get(ctx, "a")
+		assert.False(t, found)
+		assert.Zero(t, store.Len())
+	})
+}
+
+func TestBooks(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Valid Books Hit", func(t *testing.T) {
+		model := &CountingBookMockModel{}
+		books := NewBooks(model, New(NewMemoryStore(10), time.Minute))
+		key := 1
+		assert.Len(t, books.Get(ctx), 1)
+		assert.Len(t, books.Get(ctx), 1)
+		assert.Equal(t, "Learning Go", books.Find(ctx, &key).Title)
+		assert.Equal(t, "Learning Go", books.Find(ctx, &key).Title)
+		assert.EqualValues(t, 2, model.reads.Load())
+	})
+
+	t.Run("Valid Books Invalidation", func(t *testing.T) {
+		model := &CountingBookMockModel{}
+		books := NewBooks(model, New(NewMemoryStore(10), time.Minute))
+		key := 1
+		books.Get(ctx)
+		books.Find(ctx, &key)
+		book := &models.Book{}
+		book.ID = 1
+		books.Update(ctx, book)
+		books.Get(ctx)
+		books.Find(ctx, &key)
+		assert.EqualValues(t, 4, model.reads.Load())
+		books.Create(ctx, &models.Book{})
+		books.Find(ctx, &key)
+		assert.EqualValues(t, 4, model.reads.Load(), "creating a book keeps the other books")
+		books.Get(ctx)
+		books.Delete(ctx, &key)
+		books.Find(ctx, &key)
+		assert.EqualValues(t, 6, model.reads.Load())
+	})
+
+	t.Run("Valid Books Bypass", func(t *testing.T) {
+		model := &CountingBookMockModel{}
+		books := NewBooks(model, New(NewMemoryStore(10), time.Minute))
+		sparse := models.WithSelection(ctx, models.Selection{Fields: []string{"title"}})
+		books.Get(sparse)
+		books.Get(sparse)
+		assert.EqualValues(t, 2, model.reads.Load())
+	})
+
+	t.Run("Valid Books Singleflight", func(t *testing.T) {
+		model := &CountingBookMockModel{release: make(chan struct{})}
+		books := NewBooks(model, New(NewMemoryStore(10), time.Minute))
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.Len(t, books.Get(ctx), 1)
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(model.release)
+		wg.Wait()
+		assert.EqualValues(t, 1, model.reads.Load())
+	})
+
+	t.Run("Valid Books Singleflight (first caller gone)", func(t *testing.T) {
+		model := &CountingBookMockModel{release: make(chan struct{})}
+		books := NewBooks(model, New(NewMemoryStore(10), time.Minute))
+		first, cancel := context.WithCancel(ctx)
+		done := make(chan []models.Book)
+		go func() { done <- books.Get(first) }()
+		time.Sleep(20 * time.Millisecond)
+		go func() { done <- books.Get(ctx) }()
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		close(model.release)
+		assert.Len(t, <-done, 1)
+		assert.Len(t, <-done, 1)
+		assert.EqualValues(t, 1, model.reads.Load())
+	})
+
+	t.Run("Valid Books Fill From Primary", func(t *testing.T) {
+		model := &CountingBookMockModel{}
+		books := NewBooks(model, New(NewMemoryStore(10), time.Minute))
+		c, consistency := helpers.WithConsistency(ctx)
+		books.Get(c)
+		assert.Zero(t, model.replica.Load())
+		assert.False(t, consistency.Primary(), "the request keeps reading from the replica")
+	})
+
+	t.Run("Invalid Books (missing)", func(t *testing.T) {
+		model := &CountingBookMockModel{}
+		books := NewBooks(model, New(NewMemoryStore(10), time.Minute))
+		key := 2
+		assert.Nil(t, books.Find(ctx, &key))
+		assert.Nil(t, books.Find(ctx, &key))
+		assert.EqualValues(t, 2, model.reads.Load(), "missing records are not cached")
+	})
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/rizghz/api/models"
+)
+
+// Books caches the reads of a book model, its other methods go straight to
+// the model
+type Books struct {
+	models.IBookModel
+	cache *Cache
+}
+
+func NewBooks(model models.IBookModel, cache *Cache) models.IBookModel {
+	return &Books{
+		IBookModel: model,
+		cache:      cache,
+	}
+}
+
+func (m *Books) Get(ctx context.Context) []models.Book {
+	books, _ := fetch(ctx, m.cache, "books", "books", func(ctx context.Context) ([]models.Book, bool) {
+		books := m.IBookModel.Get(ctx)
+		return books, books != nil
+	})
+	return books
+}
+
+func (m *Books) Find(ctx context.Context, key *int) *models.Book {
+	book, _ := fetch(ctx, m.cache, "books", "books/"+strconv.Itoa(*key), func(ctx context.Context) (*models.Book, bool) {
+		book := m.IBookModel.Find(ctx, key)
+		return book, book != nil
+	})
+	return book
+}
+
+func (m *Books) Create(ctx context.Context, book *models.Book) (bool, *models.Book) {
+	ok, book := m.IBookModel.Create(ctx, book)
+	if ok {
+		m.cache.invalidate(ctx, "books")
+	}
+	return ok, book
+}
+
+func (m *Books) Update(ctx context.Context, book *models.Book) (bool, *models.Book) {
+	ok, updated := m.IBookModel.Update(ctx, book)
+	if ok {
+		m.cache.invalidate(ctx, "books", "books/"+strconv.Itoa(int(book.ID)))
+	}
+	return ok, updated
+}
+
+func (m *Books) Delete(ctx context.Context, key *int) bool {
+	ok := m.IBookModel.Delete(ctx, key)
+	if ok {
+		m.cache.invalidate(ctx, "books", "books/"+strconv.Itoa(*key))
+	}
+	return ok
+}
+
+// Blogs caches the reads of a blog model, its other methods go straight to
+// the model
+type Blogs struct {
+	models.IBlogModel
+	cache *Cache
+}
+
+func NewBlogs(model models.IBlogModel, cache *Cache) models.IBlogModel {
+	return &Blogs{
+		IBlogModel: model,
+		cache:      cache,
+	}
+}
+
+func (m *Blogs) Get(ctx context.Context) []models.Blog {
+	blogs, _ := fetch(ctx, m.cache, "blogs", "blogs", func(ctx context.Context) ([]models.Blog, bool) {
+		blogs := m.IBlogModel.Get(ctx)
+		return blogs, blogs != nil
+	})
+	return blogs
+}
+
+func (m *Blogs) Find(ctx context.Context, key *int) *models.Blog {
+	blog, _ := fetch(ctx, m.cache, "blogs", "blogs/"+strconv.Itoa(*key), func(ctx context.Context) (*models.Blog, bool) {
+		blog := m.IBlogModel.Find(ctx, key)
+		return blog, blog != nil
+	})
+	return blog
+}
+
+func (m *Blogs) Create(ctx context.Context, blog *models.Blog) (bool, *models.Blog) {
+	ok, blog := m.IBlogModel.Create(ctx, blog)
+	if ok {
+		m.cache.invalidate(ctx, "blogs")
+	}
+	return ok, blog
+}
+
+func (m *Blogs) Update(ctx context.Context, blog *models.Blog) (bool, *models.Blog) {
+	ok, updated := m.IBlogModel.Update(ctx, blog)
+	if ok {
+		m.cache.invalidate(ctx, "blogs", "blogs/"+strconv.Itoa(int(blog.ID)))
+	}
+	return ok, updated
+}
+
+func (m *Blogs) Delete(ctx context.Context, key *int) bool {
+	ok := m.IBlogModel.Delete(ctx, key)
+	if ok {
+		m.cache.invalidate(ctx, "blogs", "blogs/"+strconv.Itoa(*key))
+	}
+	return ok
+}
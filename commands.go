@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/rizghz/api/configs"
+	"github.com/rizghz/api/factories"
+	"github.com/rizghz/api/models"
+	"github.com/rizghz/api/search"
+	"gorm.io/gorm"
+)
+
+func migrate(db *gorm.DB, args []string) {
+	if len(args) == 0 {
+		log.Fatalf("usage: migrate up|down [steps]|status|create <name>")
+	}
+	switch args[0] {
+	case "up":
+		applied, err := models.MigrateUp(db)
+		for _, migration := range applied {
+			fmt.Printf("applied  %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("%v", err.Error())
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("[err]: steps must be a positive number")
+			}
+			steps = n
+		}
+		reverted, err := models.MigrateDown(db, steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("%v", err.Error())
+		}
+	case "status":
+		states, err := models.MigrationStatus(db)
+		if err != nil {
+			log.Fatalf("%v", err.Error())
+		}
+		for _, state := range states {
+			status := "pending"
+			if state.Applied {
+				status = "applied  " + state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%d_%s\t%s\n", state.Version, state.Name, status)
+		}
+	default:
+		log.Fatalf("[err]: unknown migrate command %q", args[0])
+	}
+}
+
+func createMigration(args []string) {
+	if len(args) == 0 {
+		log.Fatalf("usage: migrate create <name>")
+	}
+	files, err := models.CreateMigration("models/migrations", args[0])
+	for _, file := range files {
+		fmt.Printf("created  %s\n", file)
+	}
+	if err != nil {
+		log.Fatalf("%v", err.Error())
+	}
+}
+
+func seed(db *gorm.DB, conf *configs.Config, args []string) {
+	seeder, flags := factories.Seeder{}, flag.NewFlagSet("seed", flag.ExitOnError)
+	value := flags.Int64("seed", 1, "random seed for the generated data")
+	flags.IntVar(&seeder.Users, "users", 10, "number of users")
+	flags.IntVar(&seeder.BlogsPerUser, "blogs", 3, "number of blogs per user")
+	flags.IntVar(&seeder.Books, "books", 25, "number of books")
+	flags.Parse(args)
+
+	err := seeder.Run(context.Background(), factories.New(*value), models.NewUserModel(db, &conf.Jwt), models.NewBookModel(db))
+	if err != nil {
+		log.Fatalf("%v", err.Error())
+	}
+	fmt.Printf("seeded   %d users, %d blogs, %d books\n",
+		seeder.Users, seeder.Users*seeder.BlogsPerUser, seeder.Books)
+}
+
+// reindex rebuilds the search index from the database, the server must be
+// stopped as it holds the index open
+func reindex(db *gorm.DB, conf *configs.Config) {
+	count, err := search.Rebuild(context.Background(), &conf.Search, models.NewBookModel(db), models.NewBlogModel(db))
+	if err != nil {
+		log.Fatalf("%v", err.Error())
+	}
+	fmt.Printf("indexed  %d documents into %s\n", count, conf.Search.IndexPath)
+}
@@ -0,0 +1,54 @@
+package configs
+
+import (
+	"regexp"
+	"time"
+)
+
+var versionPattern = regexp.MustCompile(`^v\d+$`)
+
+type APIConfig struct {
+	Versions           []string `env:"API_VERSIONS" default:"v1,v2" yaml:"versions" toml:"versions"`
+	DefaultVersion     string   `env:"API_DEFAULT_VERSION" default:"v1" yaml:"default_version" toml:"default_version"`
+	DeprecatedVersions []string `env:"API_DEPRECATED_VERSIONS" default:"v1" yaml:"deprecated_versions" toml:"deprecated_versions"`
+	// dates are written as YYYY-MM-DD
+	DeprecatedSince string `env:"API_DEPRECATED_SINCE" yaml:"deprecated_since" toml:"deprecated_since"`
+	Sunset          string `env:"API_SUNSET" yaml:"sunset" toml:"sunset"`
+}
+
+func (c *APIConfig) Deprecated(version string) bool {
+	for _, deprecated := range c.DeprecatedVersions {
+		if deprecated == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *APIConfig) validate() []string {
+	missing := []string{}
+	known := map[string]bool{}
+	for _, version := range c.Versions {
+		if !versionPattern.MatchString(version) {
+			missing = append(missing, "API_VERSIONS (must be written as v1, v2, ...)")
+			break
+		}
+		known[version] = true
+	}
+	if !known[c.DefaultVersion] {
+		missing = append(missing, "API_DEFAULT_VERSION (must be one of API_VERSIONS)")
+	}
+	for _, version := range c.DeprecatedVersions {
+		if !known[version] {
+			missing = append(missing, "API_DEPRECATED_VERSIONS (must be part of API_VERSIONS)")
+			break
+		}
+	}
+	if _, err := time.Parse(time.DateOnly, c.DeprecatedSince); c.DeprecatedSince != "" && err != nil {
+		missing = append(missing, "API_DEPRECATED_SINCE (must be a YYYY-MM-DD date)")
+	}
+	if _, err := time.Parse(time.DateOnly, c.Sunset); c.Sunset != "" && err != nil {
+		missing = append(missing, "API_SUNSET (must be a YYYY-MM-DD date)")
+	}
+	return missing
+}
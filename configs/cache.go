@@ -0,0 +1,21 @@
+package configs
+
+import "time"
+
+// CacheConfig configures the cache of the book and blog reads
+type CacheConfig struct {
+	Enabled bool          `env:"CACHE_ENABLED" default:"true" yaml:"enabled" toml:"enabled"`
+	Size    int           `env:"CACHE_SIZE" default:"1000" yaml:"size" toml:"size"`
+	TTL     time.Duration `env:"CACHE_TTL" default:"30s" yaml:"ttl" toml:"ttl"`
+}
+
+func (c *CacheConfig) validate() []string {
+	missing := []string{}
+	if c.Enabled && c.Size <= 0 {
+		missing = append(missing, "CACHE_SIZE (must be positive)")
+	}
+	if c.Enabled && c.TTL <= 0 {
+		missing = append(missing, "CACHE_TTL (must be positive)")
+	}
+	return missing
+}
@@ -0,0 +1,211 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config values are resolved from, in order of precedence: the process
+// environment, the .env file, the CONFIG_FILE (YAML or TOML) and the
+// defaults declared on each field. Every env key also accepts a _FILE
+// suffix pointing to a file holding the value, e.g. for Docker secrets.
+type Config struct {
+	Database    DatabaseConfig    `yaml:"database" toml:"database"`
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	Jwt         JwtConfig         `yaml:"jwt" toml:"jwt"`
+	Tracing     TracingConfig     `yaml:"tracing" toml:"tracing"`
+	Log         LogConfig         `yaml:"log" toml:"log"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit" toml:"rate_limit"`
+	Security    SecurityConfig    `yaml:"security" toml:"security"`
+	API         APIConfig         `yaml:"api" toml:"api"`
+	GraphQL     GraphQLConfig     `yaml:"graphql" toml:"graphql"`
+	GRPC        GRPCConfig        `yaml:"grpc" toml:"grpc"`
+	Search      SearchConfig      `yaml:"search" toml:"search"`
+	Cache       CacheConfig       `yaml:"cache" toml:"cache"`
+	Idempotency IdempotencyConfig `yaml:"idempotency" toml:"idempotency"`
+}
+
+type Source struct {
+	Environ    []string
+	DotEnvFile string
+	ConfigFile string
+}
+
+func Load() (*Config, error) {
+	return LoadFrom(Source{
+		Environ:    os.Environ(),
+		DotEnvFile: ".env",
+		ConfigFile: os.Getenv("CONFIG_FILE"),
+	})
+}
+
+func LoadFrom(src Source) (*Config, error) {
+	conf := &Config{}
+	if err := apply(reflect.ValueOf(conf).Elem(), defaultLookup); err != nil {
+		return nil, err
+	}
+	if src.ConfigFile != "" {
+		if err := decodeFile(src.ConfigFile, conf); err != nil {
+			return nil, err
+		}
+	}
+
+	values := map[string]string{}
+	if src.DotEnvFile != "" {
+		dotenv, err := godotenv.Read(src.DotEnvFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("[err]: invalid %s file: %v", src.DotEnvFile, err)
+		}
+		for key, val := range dotenv {
+			values[key] = val
+		}
+	}
+	for _, pair := range src.Environ {
+		if key, val, found := strings.Cut(pair, "="); found {
+			values[key] = val
+		}
+	}
+	lookup := func(field reflect.StructField) (string, bool, error) {
+		key := field.Tag.Get("env")
+		if key == "" {
+			return "", false, nil
+		}
+		if val := values[key]; val != "" {
+			return val, true, nil
+		}
+		if path := values[key+"_FILE"]; path != "" {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return "", false, fmt.Errorf("[err]: %s_FILE could not be read: %v", key, err)
+			}
+			return strings.TrimRight(string(content), "\r\n"), true, nil
+		}
+		return "", false, nil
+	}
+	// report malformed and missing values together
+	if err := errors.Join(apply(reflect.ValueOf(conf).Elem(), lookup), conf.Validate()); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func (c *Config) Validate() error {
+	missing := []string{}
+	missing = append(missing, c.Database.validate()...)
+	missing = append(missing, c.Server.validate()...)
+	missing = append(missing, c.Jwt.validate()...)
+	missing = append(missing, c.Tracing.validate()...)
+	missing = append(missing, c.Log.validate()...)
+	missing = append(missing, c.RateLimit.validate()...)
+	missing = append(missing, c.Security.validate()...)
+	missing = append(missing, c.API.validate()...)
+	missing = append(missing, c.GraphQL.validate()...)
+	missing = append(missing, c.GRPC.validate()...)
+	missing = append(missing, c.Search.validate()...)
+	missing = append(missing, c.Cache.validate()...)
+	missing = append(missing, c.Idempotency.validate()...)
+	if len(missing) > 0 {
+		return fmt.Errorf("[err]: missing or invalid config: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func defaultLookup(field reflect.StructField) (string, bool, error) {
+	val, found := field.Tag.Lookup("default")
+	return val, found, nil
+}
+
+func decodeFile(path string, conf *Config) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("[err]: config file could not be read: %v", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, conf)
+	case ".toml":
+		err = toml.Unmarshal(content, conf)
+	default:
+		return fmt.Errorf("[err]: unsupported config file %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("[err]: invalid config file: %v", err)
+	}
+	return nil
+}
+
+func apply(target reflect.Value, lookup func(reflect.StructField) (string, bool, error)) error {
+	errs := []error{}
+	for i := 0; i < target.NumField(); i++ {
+		field, value := target.Type().Field(i), target.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			if err := apply(value, lookup); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		raw, found, err := lookup(field)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+		if err := set(value, raw); err != nil {
+			key := field.Tag.Get("env")
+			errs = append(errs, fmt.Errorf("[err]: %s %v", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func set(value reflect.Value, raw string) error {
+	switch {
+	case value.Type() == reflect.TypeOf(time.Duration(0)):
+		res, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.New("is not a valid duration")
+		}
+		value.SetInt(int64(res))
+	case value.Kind() == reflect.Int:
+		res, err := strconv.Atoi(raw)
+		if err != nil {
+			return errors.New("is not a valid number")
+		}
+		value.SetInt(int64(res))
+	case value.Kind() == reflect.Float64:
+		res, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return errors.New("is not a valid number")
+		}
+		value.SetFloat(res)
+	case value.Kind() == reflect.Bool:
+		res, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("is not a valid boolean")
+		}
+		value.SetBool(res)
+	case value.Kind() == reflect.Slice:
+		list := []string{}
+		for _, val := range strings.Split(raw, ",") {
+			if val = strings.TrimSpace(val); val != "" {
+				list = append(list, val)
+			}
+		}
+		value.Set(reflect.ValueOf(list))
+	default:
+		value.SetString(raw)
+	}
+	return nil
+}
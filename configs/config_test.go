@@ -0,0 +1,188 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("Valid Load (defaults)", func(t *testing.T) {
+		conf, err := LoadFrom(Source{Environ: []string{"DB_DRIVER=sqlite", "DB_NAME=api.db", "SECRET_KEY=rahasia"}})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "sqlite", conf.Database.Driver)
+			assert.Equal(t, 25, conf.Database.MaxOpenConns)
+			assert.Equal(t, 30*time.Second, conf.Server.RequestTimeout)
+			assert.Equal(t, 2*time.Hour, conf.Jwt.Expiry)
+		}
+	})
+
+	t.Run("Valid Load (precedence)", func(t *testing.T) {
+		yaml := writeFile(t, "config.yaml", "database:\n  driver: postgres\n  host: file-host\n  port: 5432\n"+
+			"  user: file-user\n  name: file-db\n  query_timeout: 3s\njwt:\n  secret: file-secret\n")
+		dotenv := writeFile(t, ".env", "DB_HOST=dotenv-host\nDB_USER=dotenv-user\n")
+		conf, err := LoadFrom(Source{
+			Environ:    []string{"DB_HOST=env-host", "DB_REPLICA_DSNS=a, b"},
+			DotEnvFile: dotenv,
+			ConfigFile: yaml,
+		})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "postgres", conf.Database.Driver)
+			assert.Equal(t, "env-host", conf.Database.Host)
+			assert.Equal(t, "dotenv-user", conf.Database.User)
+			assert.Equal(t, "file-db", conf.Database.Name)
+			assert.Equal(t, 3*time.Second, conf.Database.QueryTimeout)
+			assert.Equal(t, []string{"a", "b"}, conf.Database.ReplicaDSNs)
+			assert.Equal(t, "file-secret", conf.Jwt.Secret)
+		}
+	})
+
+	t.Run("Valid Load (toml and secrets)", func(t *testing.T) {
+		toml := writeFile(t, "config.toml", "[database]\ndriver = \"sqlite\"\nname = \"api.db\"\n\n[server]\nrequest_timeout = \"5s\"\n")
+		secret := writeFile(t, "secret", "dari-file\n")
+		conf, err := LoadFrom(Source{Environ: []string{"SECRET_KEY_FILE=" + secret}, ConfigFile: toml})
+		if assert.NoError(t, err) {
+			assert.Equal(t, "api.db", conf.Database.Name)
+			assert.Equal(t, 5*time.Second, conf.Server.RequestTimeout)
+			assert.Equal(t, "dari-file", conf.Jwt.Secret)
+		}
+	})
+
+	t.Run("Invalid Load (missing keys)", func(t *testing.T) {
+		_, err := LoadFrom(Source{Environ: []string{"DB_PORT=tiga"}})
+		if assert.Error(t, err) {
+			for _, key := range []string{"DB_PORT", "DB_NAME", "DB_HOST", "DB_USER", "SECRET_KEY"} {
+				assert.Contains(t, err.Error(), key)
+			}
+		}
+	})
+
+	t.Run("Invalid Load (driver)", func(t *testing.T) {
+		_, err := LoadFrom(Source{Environ: []string{"DB_DRIVER=oracle", "DB_DSN=x", "SECRET_KEY=rahasia"}})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "DB_DRIVER")
+		}
+	})
+
+	t.Run("Invalid Load (tracing)", func(t *testing.T) {
+		_, err := LoadFrom(Source{Environ: []string{
+			"DB_DRIVER=sqlite", "DB_NAME=api.db", "SECRET_KEY=rahasia",
+			"OTEL_TRACES_EXPORTER=otlp", "OTEL_TRACES_SAMPLER_ARG=2",
+		}})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "OTEL_EXPORTER_OTLP_ENDPOINT")
+			assert.Contains(t, err.Error(), "OTEL_TRACES_SAMPLER_ARG")
+		}
+	})
+
+	t.Run("Invalid Load (logging)", func(t *testing.T) {
+		_, err := LoadFrom(Source{Environ: []string{
+			"DB_DRIVER=sqlite", "DB_NAME=api.db", "SECRET_KEY=rahasia",
+			"LOG_LEVEL=verbose", "LOG_FORMAT=xml",
+		}})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "LOG_LEVEL")
+			assert.Contains(t, err.Error(), "LOG_FORMAT")
+		}
+	})
+
+	t.Run("Invalid Load (rate limit)", func(t *testing.T) {
+		_, err := LoadFrom(Source{Environ: []string{
+			"DB_DRIVER=sqlite", "DB_NAME=api.db", "SECRET_KEY=rahasia",
+			"RATE_LIMIT_READ=banyak", "RATE_LIMIT_AUTH=0/1m",
+		}})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "RATE_LIMIT_READ")
+			assert.Contains(t, err.Error(), "RATE_LIMIT_AUTH")
+			assert.NotContains(t, err.Error(), "RATE_LIMIT_WRITE")
+		}
+	})
+}
+
+func TestSecurity(t *testing.T) {
+	t.Run("Invalid Security (credentials with wildcard)", func(t *testing.T) {
+		_, err := LoadFrom(Source{Environ: []string{
+			"DB_DRIVER=sqlite", "DB_NAME=api.db", "SECRET_KEY=rahasia",
+			"CORS_ALLOW_CREDENTIALS=true", "BODY_LIMIT_BLOGS=banyak", "HTTP_COMPRESSION=zstd",
+			"TRUSTED_PROXIES=10.0.0.0/33",
+		}})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "CORS_ALLOW_ORIGINS")
+			assert.Contains(t, err.Error(), "TRUSTED_PROXIES")
+			assert.Contains(t, err.Error(), "BODY_LIMIT_BLOGS")
+			assert.Contains(t, err.Error(), "HTTP_COMPRESSION")
+		}
+	})
+
+	t.Run("Valid Security (explicit origins)", func(t *testing.T) {
+		conf, err := LoadFrom(Source{Environ: []string{
+			"DB_DRIVER=sqlite", "DB_NAME=api.db", "SECRET_KEY=rahasia",
+			"CORS_ALLOW_CREDENTIALS=true", "CORS_ALLOW_ORIGINS=https://a.id,https://b.id",
+			"TRUSTED_PROXIES=10.0.0.0/8,192.0.2.7",
+		}})
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{"https://a.id", "https://b.id"}, conf.Security.AllowOrigins)
+			nets, _ := conf.Security.TrustedNets()
+			if assert.Len(t, nets, 2) {
+				assert.Equal(t, "10.0.0.0/8", nets[0].String())
+				assert.Equal(t, "192.0.2.7/32", nets[1].String())
+			}
+			assert.Equal(t, "32M", conf.Security.BodyLimits.Import)
+		}
+	})
+}
+
+func TestAPI(t *testing.T) {
+	t.Run("Invalid API (versions)", func(t *testing.T) {
+		_, err := LoadFrom(Source{Environ: []string{
+			"DB_DRIVER=sqlite", "DB_NAME=api.db", "SECRET_KEY=rahasia",
+			"API_DEFAULT_VERSION=v3", "API_SUNSET=besok",
+		}})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "API_DEFAULT_VERSION")
+			assert.Contains(t, err.Error(), "API_SUNSET")
+		}
+	})
+}
+
+func TestDatabase(t *testing.T) {
+	t.Run("Valid Database Postgres DSN", func(t *testing.T) {
+		for _, pass := range []string{"", "kata sandi", `it's\here`} {
+			conf := &DatabaseConfig{Host: "db", Port: 5432, User: "api", Pass: pass, Name: "makhluk", SSLMode: "disable", TimeZone: "Asia/Jakarta"}
+			parsed, err := pgconn.ParseConfig(conf.PgSqlConnectStr())
+			if assert.NoError(t, err, pass) {
+				assert.Equal(t, pass, parsed.Password)
+				assert.Equal(t, "makhluk", parsed.Database)
+				assert.Equal(t, "api", parsed.User)
+				assert.Equal(t, "Asia/Jakarta", parsed.RuntimeParams["TimeZone"])
+			}
+		}
+	})
+}
+
+func TestRate(t *testing.T) {
+	t.Run("Valid Rate Parse", func(t *testing.T) {
+		limit, period, err := Rate("10/s").Parse()
+		if assert.NoError(t, err) {
+			assert.Equal(t, 10, limit)
+			assert.Equal(t, time.Second, period)
+		}
+	})
+
+	t.Run("Invalid Rate Parse (period)", func(t *testing.T) {
+		_, _, err := Rate("10/-1m").Parse()
+		assert.Error(t, err)
+	})
+}
@@ -1,31 +1,119 @@
 package configs
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
 
 type DatabaseConfig struct {
-	Host string
-	Port int
-	User string
-	Pass string
-	Name string
+	Driver   string `env:"DB_DRIVER" default:"mysql" yaml:"driver" toml:"driver"`
+	DSN      string `env:"DB_DSN" yaml:"dsn" toml:"dsn"`
+	Host     string `env:"DB_HOST" yaml:"host" toml:"host"`
+	Port     int    `env:"DB_PORT" yaml:"port" toml:"port"`
+	User     string `env:"DB_USER" yaml:"user" toml:"user"`
+	Pass     string `env:"DB_PASS" yaml:"pass" toml:"pass"`
+	Name     string `env:"DB_NAME" yaml:"name" toml:"name"`
+	SSLMode  string `env:"DB_SSLMODE" yaml:"sslmode" toml:"sslmode"`
+	TimeZone string `env:"DB_TIMEZONE" yaml:"timezone" toml:"timezone"`
+
+	AutoMigrate bool `env:"DB_AUTO_MIGRATE" default:"false" yaml:"auto_migrate" toml:"auto_migrate"`
+
+	MaxOpenConns    int           `env:"DB_MAX_OPEN_CONNS" default:"25" yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int           `env:"DB_MAX_IDLE_CONNS" default:"10" yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `env:"DB_CONN_MAX_LIFETIME" default:"30m" yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `env:"DB_CONN_MAX_IDLE_TIME" default:"5m" yaml:"conn_max_idle_time" toml:"conn_max_idle_time"`
+	QueryTimeout    time.Duration `env:"DB_QUERY_TIMEOUT" default:"10s" yaml:"query_timeout" toml:"query_timeout"`
+
+	ConnectRetries  int           `env:"DB_CONNECT_RETRIES" default:"5" yaml:"connect_retries" toml:"connect_retries"`
+	RetryBackoff    time.Duration `env:"DB_RETRY_BACKOFF" default:"1s" yaml:"retry_backoff" toml:"retry_backoff"`
+	RetryMaxBackoff time.Duration `env:"DB_RETRY_MAX_BACKOFF" default:"30s" yaml:"retry_max_backoff" toml:"retry_max_backoff"`
+
+	ReplicaDSNs    []string      `env:"DB_REPLICA_DSNS" yaml:"replica_dsns" toml:"replica_dsns"`
+	ReadYourWrites time.Duration `env:"DB_READ_YOUR_WRITES" default:"0s" yaml:"read_your_writes" toml:"read_your_writes"`
+}
+
+func (c *DatabaseConfig) validate() []string {
+	missing := []string{}
+	if c.Driver != "mysql" && c.Driver != "postgres" && c.Driver != "sqlite" {
+		missing = append(missing, "DB_DRIVER (must be one of mysql, postgres or sqlite)")
+	}
+	// a full DSN replaces the individual connection settings
+	if c.DSN != "" {
+		return missing
+	}
+	if c.Name == "" {
+		missing = append(missing, "DB_NAME")
+	}
+	// sqlite only needs the database file name
+	if c.Driver == "sqlite" {
+		return missing
+	}
+	if c.Host == "" {
+		missing = append(missing, "DB_HOST")
+	}
+	if c.Port == 0 {
+		missing = append(missing, "DB_PORT")
+	}
+	if c.User == "" {
+		missing = append(missing, "DB_USER")
+	}
+	return missing
+}
+
+func (c *DatabaseConfig) ConnectStr() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+	switch c.Driver {
+	case "postgres":
+		return c.PgSqlConnectStr()
+	case "sqlite":
+		return c.SqliteConnectStr()
+	}
+	return c.MySqlConnectStr()
 }
 
 func (c *DatabaseConfig) MySqlConnectStr() string {
-	format := "%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local"
-	return fmt.Sprintf(format, c.User, c.Pass, c.Host, c.Port, c.Name)
+	format := "%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=%s"
+	loc := "Local"
+	if c.TimeZone != "" {
+		loc = url.QueryEscape(c.TimeZone)
+	}
+	dsn := fmt.Sprintf(format, c.User, c.Pass, c.Host, c.Port, c.Name, loc)
+	switch c.SSLMode {
+	case "disable":
+		dsn += "&tls=false"
+	case "require", "prefer":
+		dsn += "&tls=skip-verify"
+	case "verify-ca", "verify-full":
+		dsn += "&tls=true"
+	}
+	return dsn
 }
 
 func (c *DatabaseConfig) PgSqlConnectStr() string {
 	format := "host=%s user=%s password=%s dbname=%s port=%d"
-	return fmt.Sprintf(format, c.Host, c.User, c.Pass, c.Name, c.Port)
+	dsn := fmt.Sprintf(format, pgValue(c.Host), pgValue(c.User), pgValue(c.Pass), pgValue(c.Name), c.Port)
+	if c.SSLMode != "" {
+		dsn += " sslmode=" + pgValue(c.SSLMode)
+	}
+	if c.TimeZone != "" {
+		dsn += " TimeZone=" + pgValue(c.TimeZone)
+	}
+	return dsn
+}
+
+// pgValue quotes a value of a key/value connection string, so empty values
+// and values with spaces or quotes are read back as they are
+func pgValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
 }
 
-func NewDatabaseConfig(env Env) *DatabaseConfig {
-	return &DatabaseConfig{
-		Host: env["DB_HOST"].(string),
-		Port: env["DB_PORT"].(int),
-		User: env["DB_USER"].(string),
-		Pass: env["DB_PASS"].(string),
-		Name: env["DB_NAME"].(string),
+func (c *DatabaseConfig) SqliteConnectStr() string {
+	if c.Name == ":memory:" {
+		return "file::memory:?cache=shared"
 	}
+	return fmt.Sprintf("file:%s?_pragma=foreign_keys(1)", c.Name)
 }
@@ -0,0 +1,23 @@
+package configs
+
+type GraphQLConfig struct {
+	Enabled  bool `env:"GRAPHQL_ENABLED" default:"true" yaml:"enabled" toml:"enabled"`
+	MaxDepth int  `env:"GRAPHQL_MAX_DEPTH" default:"8" yaml:"max_depth" toml:"max_depth"`
+	// every list field multiplies the cost of its selection by ListCost
+	MaxComplexity int `env:"GRAPHQL_MAX_COMPLEXITY" default:"500" yaml:"max_complexity" toml:"max_complexity"`
+	ListCost      int `env:"GRAPHQL_LIST_COST" default:"10" yaml:"list_cost" toml:"list_cost"`
+}
+
+func (c *GraphQLConfig) validate() []string {
+	missing := []string{}
+	if c.MaxDepth <= 0 {
+		missing = append(missing, "GRAPHQL_MAX_DEPTH (must be positive)")
+	}
+	if c.MaxComplexity <= 0 {
+		missing = append(missing, "GRAPHQL_MAX_COMPLEXITY (must be positive)")
+	}
+	if c.ListCost <= 0 {
+		missing = append(missing, "GRAPHQL_LIST_COST (must be positive)")
+	}
+	return missing
+}
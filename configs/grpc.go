@@ -0,0 +1,17 @@
+package configs
+
+// GRPCConfig configures the gRPC server, which serves plaintext and is meant
+// for internal traffic only, so it stays off unless enabled
+type GRPCConfig struct {
+	Enabled    bool   `env:"GRPC_ENABLED" default:"false" yaml:"enabled" toml:"enabled"`
+	Address    string `env:"GRPC_ADDR" default:":9090" yaml:"address" toml:"address"`
+	Reflection bool   `env:"GRPC_REFLECTION" default:"false" yaml:"reflection" toml:"reflection"`
+}
+
+func (c *GRPCConfig) validate() []string {
+	missing := []string{}
+	if c.Enabled && c.Address == "" {
+		missing = append(missing, "GRPC_ADDR")
+	}
+	return missing
+}
@@ -0,0 +1,18 @@
+package configs
+
+import "time"
+
+// IdempotencyConfig configures the replay of POST and PATCH requests retried
+// with the same Idempotency-Key
+type IdempotencyConfig struct {
+	Enabled bool          `env:"IDEMPOTENCY_ENABLED" default:"true" yaml:"enabled" toml:"enabled"`
+	TTL     time.Duration `env:"IDEMPOTENCY_TTL" default:"24h" yaml:"ttl" toml:"ttl"`
+}
+
+func (c *IdempotencyConfig) validate() []string {
+	missing := []string{}
+	if c.Enabled && c.TTL <= 0 {
+		missing = append(missing, "IDEMPOTENCY_TTL (must be positive)")
+	}
+	return missing
+}
@@ -0,0 +1,15 @@
+package configs
+
+import "time"
+
+type JwtConfig struct {
+	Secret string        `env:"SECRET_KEY" yaml:"secret" toml:"secret"`
+	Expiry time.Duration `env:"JWT_EXPIRY" default:"2h" yaml:"expiry" toml:"expiry"`
+}
+
+func (c *JwtConfig) validate() []string {
+	if c.Secret == "" {
+		return []string{"SECRET_KEY"}
+	}
+	return []string{}
+}
@@ -0,0 +1,19 @@
+package configs
+
+import "github.com/sirupsen/logrus"
+
+type LogConfig struct {
+	Level  string `env:"LOG_LEVEL" default:"info" yaml:"level" toml:"level"`
+	Format string `env:"LOG_FORMAT" default:"json" yaml:"format" toml:"format"`
+}
+
+func (c *LogConfig) validate() []string {
+	missing := []string{}
+	if _, err := logrus.ParseLevel(c.Level); err != nil {
+		missing = append(missing, "LOG_LEVEL (must be one of trace, debug, info, warn, error, fatal or panic)")
+	}
+	if c.Format != "json" && c.Format != "text" {
+		missing = append(missing, "LOG_FORMAT (must be one of json or text)")
+	}
+	return missing
+}
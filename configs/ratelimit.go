@@ -0,0 +1,55 @@
+package configs
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate is written as count/period, e.g. 60/1m or 10/s
+type Rate string
+
+func (r Rate) Parse() (int, time.Duration, error) {
+	count, period, found := strings.Cut(string(r), "/")
+	if !found {
+		return 0, 0, errors.New("[err]: rate must be written as count/period")
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || limit <= 0 {
+		return 0, 0, errors.New("[err]: rate count must be a positive number")
+	}
+	period = strings.TrimSpace(period)
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	window, err := time.ParseDuration(period)
+	if err != nil || window <= 0 {
+		return 0, 0, errors.New("[err]: rate period must be a positive duration")
+	}
+	return limit, window, nil
+}
+
+type RateLimitConfig struct {
+	Enabled      bool   `env:"RATE_LIMIT_ENABLED" default:"true" yaml:"enabled" toml:"enabled"`
+	Read         Rate   `env:"RATE_LIMIT_READ" default:"300/1m" yaml:"read" toml:"read"`
+	Write        Rate   `env:"RATE_LIMIT_WRITE" default:"60/1m" yaml:"write" toml:"write"`
+	Auth         Rate   `env:"RATE_LIMIT_AUTH" default:"10/1m" yaml:"auth" toml:"auth"`
+	APIKeyHeader string `env:"RATE_LIMIT_API_KEY_HEADER" default:"X-API-Key" yaml:"api_key_header" toml:"api_key_header"`
+	// keys issued to clients, only these get a bucket of their own
+	APIKeys []string `env:"RATE_LIMIT_API_KEYS" yaml:"api_keys" toml:"api_keys"`
+}
+
+func (c *RateLimitConfig) validate() []string {
+	missing := []string{}
+	if _, _, err := c.Read.Parse(); err != nil {
+		missing = append(missing, "RATE_LIMIT_READ (must be written as count/period)")
+	}
+	if _, _, err := c.Write.Parse(); err != nil {
+		missing = append(missing, "RATE_LIMIT_WRITE (must be written as count/period)")
+	}
+	if _, _, err := c.Auth.Parse(); err != nil {
+		missing = append(missing, "RATE_LIMIT_AUTH (must be written as count/period)")
+	}
+	return missing
+}
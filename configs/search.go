@@ -0,0 +1,27 @@
+package configs
+
+// SearchConfig configures the embedded index that /search uses instead of
+// the database when it is enabled. The index lives on local disk and is
+// locked by the process holding it, the reindex command needs the server
+// stopped.
+type SearchConfig struct {
+	IndexEnabled bool   `env:"SEARCH_INDEX_ENABLED" default:"false" yaml:"index_enabled" toml:"index_enabled"`
+	IndexPath    string `env:"SEARCH_INDEX_PATH" default:"search.bleve" yaml:"index_path" toml:"index_path"`
+	// typos tolerated in a word of the query, words shorter than 4 letters must match exactly
+	Fuzziness int `env:"SEARCH_FUZZINESS" default:"1" yaml:"fuzziness" toml:"fuzziness"`
+	FacetSize int `env:"SEARCH_FACET_SIZE" default:"10" yaml:"facet_size" toml:"facet_size"`
+}
+
+func (c *SearchConfig) validate() []string {
+	missing := []string{}
+	if c.IndexEnabled && c.IndexPath == "" {
+		missing = append(missing, "SEARCH_INDEX_PATH")
+	}
+	if c.Fuzziness < 0 || c.Fuzziness > 2 {
+		missing = append(missing, "SEARCH_FUZZINESS (must be between 0 and 2)")
+	}
+	if c.FacetSize <= 0 {
+		missing = append(missing, "SEARCH_FACET_SIZE (must be positive)")
+	}
+	return missing
+}
@@ -0,0 +1,93 @@
+package configs
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/bytes"
+)
+
+type SecurityConfig struct {
+	AllowOrigins     []string      `env:"CORS_ALLOW_ORIGINS" default:"*" yaml:"allow_origins" toml:"allow_origins"`
+	AllowMethods     []string      `env:"CORS_ALLOW_METHODS" default:"GET,HEAD,PUT,PATCH,POST,DELETE" yaml:"allow_methods" toml:"allow_methods"`
+	AllowHeaders     []string      `env:"CORS_ALLOW_HEADERS" yaml:"allow_headers" toml:"allow_headers"`
+	ExposeHeaders    []string      `env:"CORS_EXPOSE_HEADERS" default:"X-Request-ID,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,Retry-After" yaml:"expose_headers" toml:"expose_headers"`
+	AllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS" default:"false" yaml:"allow_credentials" toml:"allow_credentials"`
+	MaxAge           time.Duration `env:"CORS_MAX_AGE" default:"10m" yaml:"max_age" toml:"max_age"`
+
+	HSTSMaxAge            time.Duration `env:"HSTS_MAX_AGE" default:"8760h" yaml:"hsts_max_age" toml:"hsts_max_age"`
+	HSTSIncludeSubdomains bool          `env:"HSTS_INCLUDE_SUBDOMAINS" default:"false" yaml:"hsts_include_subdomains" toml:"hsts_include_subdomains"`
+	ContentSecurityPolicy string        `env:"CONTENT_SECURITY_POLICY" default:"default-src 'none'; frame-ancestors 'none'" yaml:"content_security_policy" toml:"content_security_policy"`
+	FrameOptions          string        `env:"FRAME_OPTIONS" default:"DENY" yaml:"frame_options" toml:"frame_options"`
+	ReferrerPolicy        string        `env:"REFERRER_POLICY" default:"no-referrer" yaml:"referrer_policy" toml:"referrer_policy"`
+	// addresses or CIDR ranges of the proxies whose X-Forwarded-For is
+	// trusted, without any the peer address is the client
+	TrustedProxies []string `env:"TRUSTED_PROXIES" yaml:"trusted_proxies" toml:"trusted_proxies"`
+
+	BodyLimits BodyLimitConfig `yaml:"body_limits" toml:"body_limits"`
+}
+
+// BodyLimitConfig holds the maximum request body size of each route group,
+// written as a size such as 64K or 1M
+type BodyLimitConfig struct {
+	Users   string `env:"BODY_LIMIT_USERS" default:"64K" yaml:"users" toml:"users"`
+	Books   string `env:"BODY_LIMIT_BOOKS" default:"1M" yaml:"books" toml:"books"`
+	Blogs   string `env:"BODY_LIMIT_BLOGS" default:"1M" yaml:"blogs" toml:"blogs"`
+	Import  string `env:"BODY_LIMIT_IMPORT" default:"32M" yaml:"import" toml:"import"`
+	GraphQL string `env:"BODY_LIMIT_GRAPHQL" default:"64K" yaml:"graphql" toml:"graphql"`
+}
+
+func (c *SecurityConfig) validate() []string {
+	missing := []string{}
+	// browsers refuse credentialed requests to a wildcard origin
+	if c.AllowCredentials {
+		for _, origin := range c.AllowOrigins {
+			if origin == "*" {
+				missing = append(missing, "CORS_ALLOW_ORIGINS (must list explicit origins when CORS_ALLOW_CREDENTIALS is set)")
+				break
+			}
+		}
+	}
+	if c.FrameOptions != "" && c.FrameOptions != "DENY" && c.FrameOptions != "SAMEORIGIN" {
+		missing = append(missing, "FRAME_OPTIONS (must be one of DENY or SAMEORIGIN)")
+	}
+	if _, err := c.TrustedNets(); err != nil {
+		missing = append(missing, "TRUSTED_PROXIES (must be ip addresses or CIDR ranges)")
+	}
+	limits := map[string]string{
+		"BODY_LIMIT_USERS":   c.BodyLimits.Users,
+		"BODY_LIMIT_BOOKS":   c.BodyLimits.Books,
+		"BODY_LIMIT_BLOGS":   c.BodyLimits.Blogs,
+		"BODY_LIMIT_IMPORT":  c.BodyLimits.Import,
+		"BODY_LIMIT_GRAPHQL": c.BodyLimits.GraphQL,
+	}
+	for _, key := range []string{"BODY_LIMIT_USERS", "BODY_LIMIT_BOOKS", "BODY_LIMIT_BLOGS", "BODY_LIMIT_IMPORT", "BODY_LIMIT_GRAPHQL"} {
+		if size, err := bytes.Parse(limits[key]); err != nil || size <= 0 {
+			missing = append(missing, key+" (must be a size such as 64K or 1M)")
+		}
+	}
+	return missing
+}
+
+// TrustedNets parses TrustedProxies, single addresses cover themselves
+func (c *SecurityConfig) TrustedNets() ([]*net.IPNet, error) {
+	nets := []*net.IPNet{}
+	for _, proxy := range c.TrustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if ip := net.ParseIP(proxy); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, network)
+	}
+	return nets, nil
+}
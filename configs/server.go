@@ -0,0 +1,53 @@
+package configs
+
+import "time"
+
+type ServerConfig struct {
+	Address        string        `env:"HTTP_ADDR" default:":8008" yaml:"address" toml:"address"`
+	RequestTimeout time.Duration `env:"REQUEST_TIMEOUT" default:"30s" yaml:"request_timeout" toml:"request_timeout"`
+	// exports stream whole tables and replace both the request and the write timeout
+	ExportTimeout time.Duration `env:"EXPORT_TIMEOUT" default:"10m" yaml:"export_timeout" toml:"export_timeout"`
+
+	ReadHeaderTimeout time.Duration `env:"HTTP_READ_HEADER_TIMEOUT" default:"10s" yaml:"read_header_timeout" toml:"read_header_timeout"`
+	ReadTimeout       time.Duration `env:"HTTP_READ_TIMEOUT" default:"30s" yaml:"read_timeout" toml:"read_timeout"`
+	WriteTimeout      time.Duration `env:"HTTP_WRITE_TIMEOUT" default:"60s" yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout       time.Duration `env:"HTTP_IDLE_TIMEOUT" default:"120s" yaml:"idle_timeout" toml:"idle_timeout"`
+	MaxHeaderBytes    int           `env:"HTTP_MAX_HEADER_BYTES" default:"1048576" yaml:"max_header_bytes" toml:"max_header_bytes"`
+	ShutdownTimeout   time.Duration `env:"HTTP_SHUTDOWN_TIMEOUT" default:"30s" yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	ShutdownDelay     time.Duration `env:"HTTP_SHUTDOWN_DELAY" default:"0s" yaml:"shutdown_delay" toml:"shutdown_delay"`
+	HealthTimeout     time.Duration `env:"HEALTH_CHECK_TIMEOUT" default:"2s" yaml:"health_timeout" toml:"health_timeout"`
+
+	Compression     []string `env:"HTTP_COMPRESSION" default:"br,gzip" yaml:"compression" toml:"compression"`
+	CompressMinSize int      `env:"HTTP_COMPRESS_MIN_SIZE" default:"1024" yaml:"compress_min_size" toml:"compress_min_size"`
+
+	TLSCertFile   string `env:"TLS_CERT_FILE" yaml:"tls_cert_file" toml:"tls_cert_file"`
+	TLSKeyFile    string `env:"TLS_KEY_FILE" yaml:"tls_key_file" toml:"tls_key_file"`
+	TLSSelfSigned bool   `env:"TLS_SELF_SIGNED" default:"false" yaml:"tls_self_signed" toml:"tls_self_signed"`
+}
+
+func (c *ServerConfig) TLS() bool {
+	return c.TLSSelfSigned || c.TLSCertFile != ""
+}
+
+func (c *ServerConfig) validate() []string {
+	missing := []string{}
+	if c.TLSCertFile != "" && c.TLSKeyFile == "" {
+		missing = append(missing, "TLS_KEY_FILE")
+	}
+	if c.TLSKeyFile != "" && c.TLSCertFile == "" {
+		missing = append(missing, "TLS_CERT_FILE")
+	}
+	if c.RequestTimeout <= 0 {
+		missing = append(missing, "REQUEST_TIMEOUT (must be positive)")
+	}
+	if c.ExportTimeout <= 0 {
+		missing = append(missing, "EXPORT_TIMEOUT (must be positive)")
+	}
+	for _, encoding := range c.Compression {
+		if encoding != "br" && encoding != "gzip" && encoding != "none" {
+			missing = append(missing, "HTTP_COMPRESSION (must only list br and gzip, or none)")
+			break
+		}
+	}
+	return missing
+}
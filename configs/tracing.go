@@ -0,0 +1,34 @@
+package configs
+
+type TracingConfig struct {
+	Exporter    string  `env:"OTEL_TRACES_EXPORTER" default:"none" yaml:"exporter" toml:"exporter"`
+	ServiceName string  `env:"OTEL_SERVICE_NAME" default:"api" yaml:"service_name" toml:"service_name"`
+	SampleRatio float64 `env:"OTEL_TRACES_SAMPLER_ARG" default:"1" yaml:"sample_ratio" toml:"sample_ratio"`
+
+	Endpoint string   `env:"OTEL_EXPORTER_OTLP_ENDPOINT" yaml:"endpoint" toml:"endpoint"`
+	Insecure bool     `env:"OTEL_EXPORTER_OTLP_INSECURE" default:"false" yaml:"insecure" toml:"insecure"`
+	Headers  []string `env:"OTEL_EXPORTER_OTLP_HEADERS" yaml:"headers" toml:"headers"`
+
+	File string `env:"OTEL_TRACES_FILE" yaml:"file" toml:"file"`
+}
+
+func (c *TracingConfig) validate() []string {
+	missing := []string{}
+	switch c.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if c.Endpoint == "" {
+			missing = append(missing, "OTEL_EXPORTER_OTLP_ENDPOINT")
+		}
+	case "file":
+		if c.File == "" {
+			missing = append(missing, "OTEL_TRACES_FILE")
+		}
+	default:
+		missing = append(missing, "OTEL_TRACES_EXPORTER (must be one of none, otlp, stdout or file)")
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		missing = append(missing, "OTEL_TRACES_SAMPLER_ARG (must be between 0 and 1)")
+	}
+	return missing
+}
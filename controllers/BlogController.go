@@ -29,9 +29,17 @@ func NewBlogController(model m.IBlogModel) IBlogController {
 
 func (c *BlogController) Index() echo.HandlerFunc {
 	return func(ctx echo.Context) error {
-		data := c.model.Get()
+		selection, err := parseSelection(ctx, m.BlogFields, nil)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest,
+				helpers.FormatResponse(err.Error(), nil))
+		}
+		if ctx.QueryParams().Has("q") {
+			return respondSearch(ctx, selection, c.model.Search)
+		}
+		data := c.model.Get(m.WithSelection(ctx.Request().Context(), selection))
 		return ctx.JSON(http.StatusOK,
-			helpers.FormatResponse("success", data))
+			helpers.FormatResponse("success", sparse(data, selection, nil)))
 	}
 }
 
@@ -42,9 +50,14 @@ func (c *BlogController) Observe() echo.HandlerFunc {
 			return ctx.JSON(http.StatusBadRequest,
 				helpers.FormatResponse("invalid blog id", nil))
 		}
-		data := c.model.Find(&id)
+		selection, err := parseSelection(ctx, m.BlogFields, m.BlogRelations)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest,
+				helpers.FormatResponse(err.Error(), nil))
+		}
+		data := c.model.Find(m.WithSelection(ctx.Request().Context(), selection), &id)
 		return ctx.JSON(http.StatusOK,
-			helpers.FormatResponse("success", data))
+			helpers.FormatResponse("success", sparse(data, selection, m.BlogRelations)))
 	}
 }
 
@@ -55,7 +68,7 @@ func (c *BlogController) Store() echo.HandlerFunc {
 			return ctx.JSON(http.StatusBadRequest,
 				helpers.FormatResponse("invalid blog data", blog))
 		}
-		if _, data := c.model.Create(&blog); data != nil {
+		if _, data := c.model.Create(ctx.Request().Context(), &blog); data != nil {
 			return ctx.JSON(http.StatusCreated,
 				helpers.FormatResponse("success", data))
 		}
@@ -77,7 +90,7 @@ func (c *BlogController) Edit() echo.HandlerFunc {
 			return ctx.JSON(http.StatusBadRequest,
 				helpers.FormatResponse("invalid blog data", nil))
 		}
-		if _, data := c.model.Update(&blog); data != nil {
+		if _, data := c.model.Update(ctx.Request().Context(), &blog); data != nil {
 			return ctx.JSON(http.StatusCreated,
 				helpers.FormatResponse("success", data))
 		}
@@ -93,7 +106,7 @@ func (c *BlogController) Destroy() echo.HandlerFunc {
 			return ctx.JSON(http.StatusBadRequest,
 				helpers.FormatResponse("invalid blog id", nil))
 		}
-		if res := c.model.Delete(&id); !res {
+		if res := c.model.Delete(ctx.Request().Context(), &id); !res {
 			return ctx.JSON(http.StatusInternalServerError,
 				helpers.FormatResponse("server error", nil))
 		}
@@ -2,68 +2,110 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/factories"
 	"github.com/rizghz/api/models"
 	"github.com/stretchr/testify/assert"
 )
 
 type ValidBlogMockModel struct{}
 
-func (mock *ValidBlogMockModel) Get() []models.Blog {
-	return []models.Blog{
-		{Title: "Title A", Content: "Content A", UserID: 1},
-		{Title: "Title B", Content: "Content B", UserID: 2},
-		{Title: "Title C", Content: "Content C", UserID: 3},
-	}
+func (mock *ValidBlogMockModel) Get(ctx context.Context) []models.Blog {
+	return factories.New(1).Blogs(3, 1)
 }
 
-func (mock *ValidBlogMockModel) Find(key *int) *models.Blog {
-	return &models.Blog{
-		Title:   "Title A",
-		Content: "Content A",
-		UserID:  1,
+func (mock *ValidBlogMockModel) Find(ctx context.Context, key *int) *models.Blog {
+	blog := factories.New(int64(*key)).Blog(1)
+	for _, include := range models.GetSelection(ctx).Include {
+		if include == "author" {
+			author := factories.New(int64(*key)).User()
+			blog.Author = &author
+		}
 	}
+	return &blog
 }
 
-func (mock *ValidBlogMockModel) Create(blog *models.Blog) (bool, *models.Blog) {
+func (mock *ValidBlogMockModel) Create(ctx context.Context, blog *models.Blog) (bool, *models.Blog) {
 	return true, blog
 }
 
-func (mock *ValidBlogMockModel) Update(blog *models.Blog) (bool, *models.Blog) {
+func (mock *ValidBlogMockModel) Update(ctx context.Context, blog *models.Blog) (bool, *models.Blog) {
 	return true, blog
 }
 
-func (mock *ValidBlogMockModel) Delete(key *int) bool {
+func (mock *ValidBlogMockModel) Delete(ctx context.Context, key *int) bool {
 	return true
 }
 
+func (mock *ValidBlogMockModel) GetByUsers(ctx context.Context, userIDs []uint) map[uint][]models.Blog {
+	blogs := map[uint][]models.Blog{}
+	for _, id := range userIDs {
+		blogs[id] = factories.New(int64(id)).Blogs(2, id)
+	}
+	return blogs
+}
+
+func (mock *ValidBlogMockModel) Each(ctx context.Context, fn func(blog *models.Blog) error) error {
+	for _, blog := range mock.Get(ctx) {
+		if err := fn(&blog); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mock *ValidBlogMockModel) Search(ctx context.Context, query models.SearchQuery) *models.SearchResult {
+	result := &models.SearchResult{Page: query.Page, PerPage: query.PerPage}
+	for i, record := range factories.New(1).Blogs(3, 1) {
+		record.ID = uint(i + 1)
+		result.Hits = append(result.Hits, models.SearchHit{
+			Resource: "blogs", ID: record.ID, Score: float64(3 - i), Snippet: record.Title, Record: record,
+		})
+	}
+	result.Total = int64(len(result.Hits))
+	return result
+}
+
 type InvalidBlogMockModel struct{}
 
-func (mock *InvalidBlogMockModel) Get() []models.Blog {
+func (mock *InvalidBlogMockModel) Get(ctx context.Context) []models.Blog {
 	return nil
 }
 
-func (mock *InvalidBlogMockModel) Find(key *int) *models.Blog {
+func (mock *InvalidBlogMockModel) Find(ctx context.Context, key *int) *models.Blog {
 	return nil
 }
 
-func (mock *InvalidBlogMockModel) Create(blog *models.Blog) (bool, *models.Blog) {
+func (mock *InvalidBlogMockModel) Create(ctx context.Context, blog *models.Blog) (bool, *models.Blog) {
 	return false, nil
 }
 
-func (mock *InvalidBlogMockModel) Update(blog *models.Blog) (bool, *models.Blog) {
+func (mock *InvalidBlogMockModel) Update(ctx context.Context, blog *models.Blog) (bool, *models.Blog) {
 	return false, nil
 }
 
-func (mock *InvalidBlogMockModel) Delete(key *int) bool {
+func (mock *InvalidBlogMockModel) Delete(ctx context.Context, key *int) bool {
 	return false
 }
 
+func (mock *InvalidBlogMockModel) GetByUsers(ctx context.Context, userIDs []uint) map[uint][]models.Blog {
+	return nil
+}
+
+func (mock *InvalidBlogMockModel) Each(ctx context.Context, fn func(blog *models.Blog) error) error {
+	return nil
+}
+
+func (mock *InvalidBlogMockModel) Search(ctx context.Context, query models.SearchQuery) *models.SearchResult {
+	return nil
+}
+
 type BlogResponseA struct {
 	Data    []models.Blog `json:"data"`
 	Message string        `json:"message"`
@@ -150,6 +192,60 @@ func TestBlogObserve(t *testing.T) {
 	})
 }
 
+func TestBlogSelection(t *testing.T) {
+	e := echo.New()
+	controller := NewBlogController(&ValidBlogMockModel{})
+	e.GET("/blogs", controller.Index())
+	e.GET("/blogs/:id", controller.Observe())
+
+	t.Run("Valid Blog Selection (fields)", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/blogs?fields=title,user_id", nil))
+		res := struct {
+			Data []map[string]any `json:"data"`
+		}{}
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		if assert.Len(t, res.Data, 3) {
+			assert.Len(t, res.Data[0], 3)
+			assert.Contains(t, res.Data[0], "ID")
+			assert.Contains(t, res.Data[0], "title")
+			assert.Contains(t, res.Data[0], "user_id")
+		}
+	})
+
+	t.Run("Valid Blog Selection (include)", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/blogs/1?fields=title&include=author", nil))
+		res := struct {
+			Data map[string]any `json:"data"`
+		}{}
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, res.Data, 3)
+		author, _ := res.Data["author"].(map[string]any)
+		assert.Contains(t, author, "email")
+		assert.NotContains(t, author, "password")
+		assert.NotContains(t, author, "token")
+	})
+
+	t.Run("Invalid Blog Selection (field)", func(t *testing.T) {
+		rec, res := httptest.NewRecorder(), BlogResponseB{}
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/blogs/1?fields=title,secret", nil))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "unknown field secret", res.Message)
+	})
+
+	t.Run("Invalid Blog Selection (include on list)", func(t *testing.T) {
+		rec, res := httptest.NewRecorder(), BlogResponseA{}
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/blogs?include=author", nil))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "cannot include author", res.Message)
+	})
+}
+
 func TestBlogStore(t *testing.T) {
 	e := echo.New()
 	data := []byte(`{"title": "Title Baru", "content": "Content Baru", "user_id": 1}`)
@@ -1,8 +1,15 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/rizghz/api/helpers"
@@ -19,8 +26,26 @@ type IBookController interface {
 	Store() echo.HandlerFunc
 	Edit() echo.HandlerFunc
 	Destroy() echo.HandlerFunc
+	Export() echo.HandlerFunc
+	Import() echo.HandlerFunc
 }
 
+type BookImportError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+type BookImportResult struct {
+	DryRun     bool              `json:"dry_run"`
+	Total      int               `json:"total"`
+	Imported   int               `json:"imported"`
+	Duplicates int               `json:"duplicates"`
+	Failed     int               `json:"failed"`
+	Errors     []BookImportError `json:"errors"`
+}
+
+var bookColumns = []string{"id", "isbn", "title", "author", "publisher", "created_at", "updated_at"}
+
 func NewBookController(model m.IBookModel) IBookController {
 	return &BookController{
 		model: model,
@@ -29,9 +54,17 @@ func NewBookController(model m.IBookModel) IBookController {
 
 func (c *BookController) Index() echo.HandlerFunc {
 	return func(ctx echo.Context) error {
-		data := c.model.Get()
+		selection, err := parseSelection(ctx, m.BookFields, nil)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest,
+				helpers.FormatResponse(err.Error(), nil))
+		}
+		if ctx.QueryParams().Has("q") {
+			return respondSearch(ctx, selection, c.model.Search)
+		}
+		data := c.model.Get(m.WithSelection(ctx.Request().Context(), selection))
 		return ctx.JSON(http.StatusOK,
-			helpers.FormatResponse("success", data))
+			helpers.FormatResponse("success", sparse(data, selection, nil)))
 	}
 }
 
@@ -42,9 +75,14 @@ func (c *BookController) Observe() echo.HandlerFunc {
 			return ctx.JSON(http.StatusBadRequest,
 				helpers.FormatResponse("invalid book id", nil))
 		}
-		data := c.model.Find(&id)
+		selection, err := parseSelection(ctx, m.BookFields, nil)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest,
+				helpers.FormatResponse(err.Error(), nil))
+		}
+		data := c.model.Find(m.WithSelection(ctx.Request().Context(), selection), &id)
 		return ctx.JSON(http.StatusOK,
-			helpers.FormatResponse("success", data))
+			helpers.FormatResponse("success", sparse(data, selection, nil)))
 	}
 }
 
@@ -55,7 +93,7 @@ func (c *BookController) Store() echo.HandlerFunc {
 			return ctx.JSON(http.StatusBadRequest,
 				helpers.FormatResponse("invalid book data", nil))
 		}
-		if _, data := c.model.Create(&book); data != nil {
+		if _, data := c.model.Create(ctx.Request().Context(), &book); data != nil {
 			return ctx.JSON(http.StatusCreated,
 				helpers.FormatResponse("success", data))
 		}
@@ -77,7 +115,7 @@ func (c *BookController) Edit() echo.HandlerFunc {
 			return ctx.JSON(http.StatusBadRequest,
 				helpers.FormatResponse("invalid book data", nil))
 		}
-		if _, data := c.model.Update(&book); data != nil {
+		if _, data := c.model.Update(ctx.Request().Context(), &book); data != nil {
 			return ctx.JSON(http.StatusCreated,
 				helpers.FormatResponse("success", data))
 		}
@@ -93,10 +131,168 @@ func (c *BookController) Destroy() echo.HandlerFunc {
 			return ctx.JSON(http.StatusBadRequest,
 				helpers.FormatResponse("invalid book id", nil))
 		}
-		if res := c.model.Delete(&id); !res {
+		if res := c.model.Delete(ctx.Request().Context(), &id); !res {
 			return ctx.JSON(http.StatusInternalServerError,
 				helpers.FormatResponse("server error", nil))
 		}
 		return ctx.JSON(http.StatusNoContent, nil)
 	}
 }
+
+func (c *BookController) Export() echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		format := strings.ToLower(ctx.QueryParam("format"))
+		if accept := ctx.Request().Header.Get(echo.HeaderAccept); format == "" && accept != "" {
+			format = helpers.NegotiateFormat(accept, "csv", "ndjson", "xlsx")
+		}
+		if format == "" {
+			format = "csv"
+		}
+		res := ctx.Response()
+		writer, err := helpers.NewTableWriter(format, res)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest,
+				helpers.FormatResponse("invalid export format", nil))
+		}
+		res.Header().Set(echo.HeaderContentType, helpers.TableContentType(format))
+		res.Header().Set(echo.HeaderContentDisposition,
+			fmt.Sprintf("attachment; filename=books.%s", format))
+		res.WriteHeader(http.StatusOK)
+		err = writer.WriteHeader(bookColumns)
+		if err == nil {
+			err = c.model.Each(ctx.Request().Context(), func(book *m.Book) error {
+				return writer.WriteRow([]any{
+					book.ID, book.ISBN, book.Title, book.Author, book.Publisher,
+					book.CreatedAt.Format(time.RFC3339),
+					book.UpdatedAt.Format(time.RFC3339),
+				})
+			})
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			// the status is already sent, closing the connection is the only way
+			// to tell the client that the file is truncated
+			helpers.Log(ctx.Request().Context()).Error(err.Error())
+			panic(http.ErrAbortHandler)
+		}
+		return nil
+	}
+}
+
+func (c *BookController) Import() echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		file, err := ctx.FormFile("file")
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest,
+				helpers.FormatResponse("invalid import file", nil))
+		}
+		format := strings.ToLower(ctx.FormValue("format"))
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(file.Filename)), ".")
+		}
+		mapping := map[string]string{}
+		if raw := ctx.FormValue("mapping"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+				return ctx.JSON(http.StatusBadRequest,
+					helpers.FormatResponse("invalid column mapping", nil))
+			}
+		}
+		dryRun, _ := strconv.ParseBool(ctx.FormValue("dry_run"))
+
+		src, err := file.Open()
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest,
+				helpers.FormatResponse("invalid import file", nil))
+		}
+		defer src.Close()
+		reader, err := helpers.NewTableReader(format, src)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest,
+				helpers.FormatResponse("invalid import format", nil))
+		}
+
+		result := BookImportResult{DryRun: dryRun, Errors: []BookImportError{}}
+		seen := map[string]bool{}
+		for row := 1; ; row++ {
+			record, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			result.Total++
+			if err != nil {
+				result.Failed++
+				result.Errors = append(result.Errors, BookImportError{row, err.Error()})
+				continue
+			}
+			book := mapBookRecord(record, mapping)
+			if book.Title == "" || book.Author == "" {
+				result.Failed++
+				result.Errors = append(result.Errors, BookImportError{row, "title and author are required"})
+				continue
+			}
+			keys := []string{"title:" + strings.ToLower(book.Title+"|"+book.Author)}
+			if book.ISBN != "" {
+				keys = append(keys, "isbn:"+book.ISBN)
+			}
+			duplicate := false
+			for _, key := range keys {
+				duplicate = duplicate || seen[key]
+				seen[key] = true
+			}
+			if duplicate || c.model.Duplicate(ctx.Request().Context(), &book) != nil {
+				result.Duplicates++
+				result.Errors = append(result.Errors, BookImportError{row, "duplicate book"})
+				continue
+			}
+			if !dryRun {
+				if _, data := c.model.Create(ctx.Request().Context(), &book); data == nil {
+					result.Failed++
+					result.Errors = append(result.Errors, BookImportError{row, "server error"})
+					continue
+				}
+			}
+			result.Imported++
+		}
+
+		if ctx.FormValue("report") == "csv" {
+			return writeImportReport(ctx, result.Errors)
+		}
+		return ctx.JSON(http.StatusOK,
+			helpers.FormatResponse("success", result))
+	}
+}
+
+func mapBookRecord(record map[string]string, mapping map[string]string) m.Book {
+	fields := make(map[string]string, len(record))
+	for col, val := range record {
+		if target, found := mapping[col]; found {
+			col = target
+		}
+		fields[strings.ToLower(col)] = val
+	}
+	return m.Book{
+		ISBN:      strings.ReplaceAll(fields["isbn"], "-", ""),
+		Title:     fields["title"],
+		Author:    fields["author"],
+		Publisher: fields["publisher"],
+	}
+}
+
+func writeImportReport(ctx echo.Context, report []BookImportError) error {
+	res := ctx.Response()
+	res.Header().Set(echo.HeaderContentType, helpers.TableContentType("csv"))
+	res.Header().Set(echo.HeaderContentDisposition, "attachment; filename=import-report.csv")
+	res.WriteHeader(http.StatusOK)
+	writer, _ := helpers.NewTableWriter("csv", res)
+	if err := writer.WriteHeader([]string{"row", "message"}); err != nil {
+		return err
+	}
+	for _, item := range report {
+		if err := writer.WriteRow([]any{item.Row, item.Message}); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
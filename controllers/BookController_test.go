@@ -1,69 +1,108 @@
 package controllers
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/factories"
 	"github.com/rizghz/api/models"
 	"github.com/stretchr/testify/assert"
 )
 
 type ValidBookMockModel struct{}
 
-func (mock *ValidBookMockModel) Get() []models.Book {
-	return []models.Book{
-		{Title: "Buku A", Author: "Author A", Publisher: "Publisher A"},
-		{Title: "Buku B", Author: "Author B", Publisher: "Publisher B"},
-		{Title: "Buku C", Author: "Author C", Publisher: "Publisher C"},
-	}
+func (mock *ValidBookMockModel) Get(ctx context.Context) []models.Book {
+	return factories.New(1).Books(3)
 }
 
-func (mock *ValidBookMockModel) Find(key *int) *models.Book {
-	return &models.Book{
-		Title:     "Buku A",
-		Author:    "Author A",
-		Publisher: "Publisher A",
-	}
+func (mock *ValidBookMockModel) Find(ctx context.Context, key *int) *models.Book {
+	book := factories.New(int64(*key)).Book()
+	return &book
 }
 
-func (mock *ValidBookMockModel) Create(book *models.Book) (bool, *models.Book) {
+func (mock *ValidBookMockModel) Create(ctx context.Context, book *models.Book) (bool, *models.Book) {
 	return true, book
 }
 
-func (mock *ValidBookMockModel) Update(book *models.Book) (bool, *models.Book) {
+func (mock *ValidBookMockModel) Update(ctx context.Context, book *models.Book) (bool, *models.Book) {
 	return true, book
 }
 
-func (mock *ValidBookMockModel) Delete(key *int) bool {
+func (mock *ValidBookMockModel) Delete(ctx context.Context, key *int) bool {
 	return true
 }
 
+func (mock *ValidBookMockModel) Each(ctx context.Context, fn func(book *models.Book) error) error {
+	for _, book := range mock.Get(ctx) {
+		if err := fn(&book); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mock *ValidBookMockModel) Duplicate(ctx context.Context, book *models.Book) *models.Book {
+	if book.ISBN == "9786020000001" {
+		return book
+	}
+	return nil
+}
+
+func (mock *ValidBookMockModel) Search(ctx context.Context, query models.SearchQuery) *models.SearchResult {
+	result := &models.SearchResult{Page: query.Page, PerPage: query.PerPage}
+	for i, record := range factories.New(1).Books(3) {
+		record.ID = uint(i + 1)
+		result.Hits = append(result.Hits, models.SearchHit{
+			Resource: "books", ID: record.ID, Score: float64(3 - i), Snippet: record.Title, Record: record,
+		})
+	}
+	result.Total = int64(len(result.Hits))
+	return result
+}
+
 type InvalidBookMockModel struct{}
 
-func (mock *InvalidBookMockModel) Get() []models.Book {
+func (mock *InvalidBookMockModel) Get(ctx context.Context) []models.Book {
 	return nil
 }
 
-func (mock *InvalidBookMockModel) Find(key *int) *models.Book {
+func (mock *InvalidBookMockModel) Find(ctx context.Context, key *int) *models.Book {
 	return nil
 }
 
-func (mock *InvalidBookMockModel) Create(book *models.Book) (bool, *models.Book) {
+func (mock *InvalidBookMockModel) Create(ctx context.Context, book *models.Book) (bool, *models.Book) {
 	return false, nil
 }
 
-func (mock *InvalidBookMockModel) Update(book *models.Book) (bool, *models.Book) {
+func (mock *InvalidBookMockModel) Update(ctx context.Context, book *models.Book) (bool, *models.Book) {
 	return false, nil
 }
 
-func (mock *InvalidBookMockModel) Delete(key *int) bool {
+func (mock *InvalidBookMockModel) Delete(ctx context.Context, key *int) bool {
 	return false
 }
 
+func (mock *InvalidBookMockModel) Each(ctx context.Context, fn func(book *models.Book) error) error {
+	return errors.New("[err]: connection reset")
+}
+
+func (mock *InvalidBookMockModel) Duplicate(ctx context.Context, book *models.Book) *models.Book {
+	return nil
+}
+
+func (mock *InvalidBookMockModel) Search(ctx context.Context, query models.SearchQuery) *models.SearchResult {
+	return nil
+}
+
 type BookResponseA struct {
 	Data    []models.Book `json:"data"`
 	Message string        `json:"message"`
@@ -74,6 +113,25 @@ type BookResponseB struct {
 	Message string      `json:"message"`
 }
 
+type BookResponseC struct {
+	Data    BookImportResult `json:"data"`
+	Message string           `json:"message"`
+}
+
+func newBookImportRequest(filename string, content string, fields map[string]string) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", filename)
+	part.Write([]byte(content))
+	for key, val := range fields {
+		writer.WriteField(key, val)
+	}
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/books/import", body)
+	req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	return req
+}
+
 func TestBookIndex(t *testing.T) {
 	e := echo.New()
 	req, res := httptest.NewRequest(http.MethodGet, "/books", nil), BookResponseA{}
@@ -105,6 +163,48 @@ func TestBookIndex(t *testing.T) {
 	})
 }
 
+func TestBookSearch(t *testing.T) {
+	e := echo.New()
+	e.GET("/books", NewBookController(&ValidBookMockModel{}).Index())
+
+	t.Run("Valid Book Search", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/books?q=golang&fields=title", nil))
+		res := struct {
+			Data struct {
+				Hits []struct {
+					Resource string         `json:"resource"`
+					Record   map[string]any `json:"record"`
+				} `json:"hits"`
+				Total int64 `json:"total"`
+			} `json:"data"`
+		}{}
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.EqualValues(t, 3, res.Data.Total)
+		if assert.Len(t, res.Data.Hits, 3) {
+			assert.Equal(t, "books", res.Data.Hits[0].Resource)
+			assert.Len(t, res.Data.Hits[0].Record, 2)
+		}
+	})
+
+	t.Run("Invalid Book Search (empty)", func(t *testing.T) {
+		rec, res := httptest.NewRecorder(), BookResponseB{}
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/books?q=+", nil))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "missing search query", res.Message)
+	})
+
+	t.Run("Invalid Book Search (server)", func(t *testing.T) {
+		e := echo.New()
+		e.GET("/books", NewBookController(&InvalidBookMockModel{}).Index())
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/books?q=golang", nil))
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
 func TestBookObserve(t *testing.T) {
 	e := echo.New()
 
@@ -298,3 +398,185 @@ func TestBookDestroy(t *testing.T) {
 		}
 	})
 }
+
+func TestBookExport(t *testing.T) {
+	e := echo.New()
+
+	t.Run("Valid Book Export (csv)", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/books/export?format=csv", nil)
+		controller := NewBookController(&ValidBookMockModel{})
+		rec := httptest.NewRecorder()
+		e.GET("/books/export", controller.Export())
+		e.ServeHTTP(rec, req)
+		if assert.NoError(t, nil, controller.Export()) {
+			lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, rec.Header().Get(echo.HeaderContentType), "text/csv")
+			assert.Equal(t, "id,isbn,title,author,publisher,created_at,updated_at", lines[0])
+			assert.Len(t, lines, 4)
+		}
+	})
+
+	t.Run("Valid Book Export (ndjson)", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/books/export?format=ndjson", nil)
+		controller := NewBookController(&ValidBookMockModel{})
+		rec := httptest.NewRecorder()
+		e.GET("/books/export", controller.Export())
+		e.ServeHTTP(rec, req)
+		if assert.NoError(t, nil, controller.Export()) {
+			lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+			row := map[string]any{}
+			json.Unmarshal([]byte(lines[0]), &row)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Len(t, lines, 3)
+			assert.Equal(t, factories.New(1).Book().Title, row["title"])
+		}
+	})
+
+	t.Run("Valid Book Export (Accept)", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/books/export", nil)
+		req.Header.Set(echo.HeaderAccept, "application/x-ndjson")
+		controller := NewBookController(&ValidBookMockModel{})
+		rec := httptest.NewRecorder()
+		e.GET("/books/export", controller.Export())
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get(echo.HeaderContentType))
+	})
+
+	t.Run("Valid Book Export (xlsx)", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/books/export?format=xlsx", nil)
+		controller := NewBookController(&ValidBookMockModel{})
+		rec := httptest.NewRecorder()
+		e.GET("/books/export", controller.Export())
+		e.ServeHTTP(rec, req)
+		if assert.NoError(t, nil, controller.Export()) {
+			archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			if assert.NoError(t, err) {
+				assert.Len(t, archive.File, 5)
+			}
+		}
+	})
+
+	t.Run("Invalid Book Export (aborted)", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/books/export?format=csv", nil)
+		rec := httptest.NewRecorder()
+		ctx := e.NewContext(req, rec)
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			NewBookController(&InvalidBookMockModel{}).Export()(ctx)
+		})
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Invalid Book Export (format)", func(t *testing.T) {
+		req, res := httptest.NewRequest(http.MethodGet, "/books/export?format=pdf", nil), BookResponseB{}
+		controller := NewBookController(&ValidBookMockModel{})
+		rec := httptest.NewRecorder()
+		e.GET("/books/export", controller.Export())
+		e.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		if assert.NoError(t, nil, controller.Export()) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, "invalid export format", res.Message)
+		}
+	})
+}
+
+func TestBookImport(t *testing.T) {
+	e := echo.New()
+	data := "Judul,Penulis,Penerbit,ISBN\n" +
+		"Buku Baru,Author Baru,Publisher Baru,978-602-0000-00-2\n" +
+		"Buku Lama,Author Lama,Publisher Lama,978-602-0000-00-1\n" +
+		"Buku Baru,Author Baru,Publisher Baru,\n" +
+		",Author Kosong,Publisher Kosong,\n"
+	mapping := `{"Judul":"title","Penulis":"author","Penerbit":"publisher"}`
+
+	t.Run("Valid Book Import (csv)", func(t *testing.T) {
+		req, res := newBookImportRequest("books.csv", data, map[string]string{"mapping": mapping}), BookResponseC{}
+		controller := NewBookController(&ValidBookMockModel{})
+		rec := httptest.NewRecorder()
+		e.POST("/books/import", controller.Import())
+		e.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		if assert.NoError(t, nil, controller.Import()) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "success", res.Message)
+			assert.Equal(t, 4, res.Data.Total)
+			assert.Equal(t, 1, res.Data.Imported)
+			assert.Equal(t, 2, res.Data.Duplicates)
+			assert.Equal(t, 1, res.Data.Failed)
+			assert.Len(t, res.Data.Errors, 3)
+		}
+	})
+
+	t.Run("Valid Book Import (ndjson, dry run)", func(t *testing.T) {
+		lines := `{"title":"Buku Baru","author":"Author Baru","isbn":9786020000002}` + "\n"
+		req, res := newBookImportRequest("books.ndjson", lines, map[string]string{"dry_run": "true"}), BookResponseC{}
+		controller := NewBookController(&InvalidBookMockModel{})
+		rec := httptest.NewRecorder()
+		e.POST("/books/import", controller.Import())
+		e.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		if assert.NoError(t, nil, controller.Import()) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.True(t, res.Data.DryRun)
+			assert.Equal(t, 1, res.Data.Imported)
+			assert.Empty(t, res.Data.Errors)
+		}
+	})
+
+	t.Run("Valid Book Import (report)", func(t *testing.T) {
+		req := newBookImportRequest("books.csv", data, map[string]string{"mapping": mapping, "report": "csv"})
+		controller := NewBookController(&ValidBookMockModel{})
+		rec := httptest.NewRecorder()
+		e.POST("/books/import", controller.Import())
+		e.ServeHTTP(rec, req)
+		if assert.NoError(t, nil, controller.Import()) {
+			lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "row,message", lines[0])
+			assert.Len(t, lines, 4)
+		}
+	})
+
+	t.Run("Invalid Book Import (server)", func(t *testing.T) {
+		req, res := newBookImportRequest("books.csv", data, map[string]string{"mapping": mapping}), BookResponseC{}
+		controller := NewBookController(&InvalidBookMockModel{})
+		rec := httptest.NewRecorder()
+		e.POST("/books/import", controller.Import())
+		e.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		if assert.NoError(t, nil, controller.Import()) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, 0, res.Data.Imported)
+			assert.Equal(t, 3, res.Data.Failed)
+		}
+	})
+
+	t.Run("Invalid Book Import (format)", func(t *testing.T) {
+		req, res := newBookImportRequest("books.pdf", data, nil), BookResponseB{}
+		controller := NewBookController(&ValidBookMockModel{})
+		rec := httptest.NewRecorder()
+		e.POST("/books/import", controller.Import())
+		e.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		if assert.NoError(t, nil, controller.Import()) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, "invalid import format", res.Message)
+		}
+	})
+
+	t.Run("Invalid Book Import (file)", func(t *testing.T) {
+		req, res := httptest.NewRequest(http.MethodPost, "/books/import", nil), BookResponseB{}
+		controller := NewBookController(&ValidBookMockModel{})
+		rec := httptest.NewRecorder()
+		e.POST("/books/import", controller.Import())
+		e.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		if assert.NoError(t, nil, controller.Import()) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, "invalid import file", res.Message)
+		}
+	})
+}
@@ -1,7 +1,18 @@
 package controllers
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
 	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/helpers"
+	m "github.com/rizghz/api/models"
 )
 
 type Controller interface {
@@ -11,3 +22,146 @@ type Controller interface {
 	edit() echo.HandlerFunc
 	delete() echo.HandlerFunc
 }
+
+// parseSelection reads ?fields= and ?include= against the whitelist of a
+// resource, relations is nil where nothing may be included
+func parseSelection(ctx echo.Context, fields m.Fields, relations m.Relations) (m.Selection, error) {
+	selection := m.Selection{}
+	for _, field := range list(ctx.QueryParam("fields")) {
+		if _, found := fields[m.FieldName(field)]; !found {
+			return selection, fmt.Errorf("unknown field %s", field)
+		}
+		selection.Fields = append(selection.Fields, field)
+	}
+	for _, include := range list(ctx.QueryParam("include")) {
+		if _, found := relations[include]; !found {
+			return selection, fmt.Errorf("cannot include %s", include)
+		}
+		selection.Include = append(selection.Include, include)
+	}
+	return selection, nil
+}
+
+const searchMaxLength = 200
+
+var errNoIndex = errors.New("filters need the search index")
+
+// parseSearch reads ?q=, ?page=, ?per_page= and the filters of a search
+func parseSearch(ctx echo.Context) (m.SearchQuery, error) {
+	query := m.SearchQuery{Text: strings.TrimSpace(ctx.QueryParam("q")), Page: 1, PerPage: m.SearchPerPage}
+	for _, filter := range m.SearchFilters {
+		if value := ctx.QueryParam(filter); value != "" {
+			if query.Filters == nil {
+				query.Filters = map[string]string{}
+			}
+			query.Filters[filter] = value
+		}
+	}
+	if len(query.Terms()) == 0 {
+		return query, fmt.Errorf("missing search query")
+	}
+	if len(query.Text) > searchMaxLength {
+		return query, fmt.Errorf("search query is longer than %d characters", searchMaxLength)
+	}
+	for param, value := range map[string]*int{"page": &query.Page, "per_page": &query.PerPage} {
+		if raw := ctx.QueryParam(param); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 {
+				return query, fmt.Errorf("invalid %s", param)
+			}
+			*value = parsed
+		}
+	}
+	if query.Page > m.SearchMaxPage {
+		return query, fmt.Errorf("page is over %d", m.SearchMaxPage)
+	}
+	if query.PerPage > m.SearchMaxPerPage {
+		return query, fmt.Errorf("per_page is over %d", m.SearchMaxPerPage)
+	}
+	return query, nil
+}
+
+// respondSearch answers the ?q= of a list with a page of ranked hits
+func respondSearch(ctx echo.Context, selection m.Selection, search func(context.Context, m.SearchQuery) *m.SearchResult) error {
+	query, err := parseSearch(ctx)
+	if err == nil && len(query.Filters) > 0 {
+		err = errNoIndex
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest,
+			helpers.FormatResponse(err.Error(), nil))
+	}
+	result := search(ctx.Request().Context(), query)
+	if result == nil {
+		return ctx.JSON(http.StatusInternalServerError,
+			helpers.FormatResponse("server error", nil))
+	}
+	for i := range result.Hits {
+		result.Hits[i].Record = sparse(result.Hits[i].Record, selection, nil)
+	}
+	return ctx.JSON(http.StatusOK,
+		helpers.FormatResponse("success", result))
+}
+
+func list(param string) []string {
+	values := []string{}
+	for _, value := range strings.Split(param, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// sparse drops the fields left out of a selection from the rendered data,
+// included relations are rendered with their own whitelist only
+func sparse(data any, selection m.Selection, relations m.Relations) any {
+	if len(selection.Fields) == 0 && len(selection.Include) == 0 {
+		return data
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return data
+	}
+	value, err := helpers.DecodeOrdered(bytes.NewReader(encoded))
+	if err != nil {
+		return data
+	}
+	// the id is always rendered, as it is always selected
+	keep := map[string]bool{"id": true}
+	for _, field := range selection.Fields {
+		keep[m.FieldName(field)] = true
+	}
+	included := map[string]m.Fields{}
+	for _, include := range selection.Include {
+		included[include] = relations[include].Fields
+	}
+	return project(value, func(key string) bool {
+		return len(selection.Fields) == 0 || keep[m.FieldName(key)]
+	}, included)
+}
+
+func project(value any, keep func(key string) bool, included map[string]m.Fields) any {
+	switch value := value.(type) {
+	case []any:
+		for i := range value {
+			value[i] = project(value[i], keep, included)
+		}
+	case *helpers.OrderedMap:
+		object := &helpers.OrderedMap{Values: map[string]any{}}
+		for _, key := range value.Keys {
+			if fields, found := included[key]; found {
+				object.Keys = append(object.Keys, key)
+				object.Values[key] = project(value.Values[key], func(key string) bool {
+					_, found := fields[m.FieldName(key)]
+					return found
+				}, nil)
+			} else if keep(key) {
+				object.Keys = append(object.Keys, key)
+				object.Values[key] = value.Values[key]
+			}
+		}
+		return object
+	}
+	return value
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/graph"
+	"github.com/rizghz/api/helpers"
+	"github.com/rizghz/api/routes/middleware"
+)
+
+type GraphQLController struct {
+	executor *graph.Executor
+}
+
+type IGraphQLController interface {
+	Query() echo.HandlerFunc
+}
+
+func NewGraphQLController(executor *graph.Executor) IGraphQLController {
+	return &GraphQLController{
+		executor: executor,
+	}
+}
+
+func (c *GraphQLController) Query() echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		req := graph.Request{}
+		if err := ctx.Bind(&req); err != nil || req.Query == "" {
+			return ctx.JSON(http.StatusBadRequest,
+				map[string]any{"errors": []map[string]string{{"message": "invalid graphql request"}}})
+		}
+		// GET requests carry the variables as JSON and may only read
+		if ctx.Request().Method == http.MethodGet {
+			req.ReadOnly = true
+			if variables := ctx.QueryParam("variables"); variables != "" {
+				if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+					return ctx.JSON(http.StatusBadRequest,
+						map[string]any{"errors": []map[string]string{{"message": "invalid graphql variables"}}})
+				}
+			}
+		}
+		reqCtx := helpers.WithUserID(ctx.Request().Context(), middleware.ExtractToken(ctx))
+		result := c.executor.Do(reqCtx, req)
+		// errors raised before execution carry no path, the query itself is wrong
+		if result.Data == nil && result.HasErrors() && result.Errors[0].Path == nil {
+			return ctx.JSON(http.StatusBadRequest, result)
+		}
+		return ctx.JSON(http.StatusOK, result)
+	}
+}
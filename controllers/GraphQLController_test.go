@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/configs"
+	"github.com/rizghz/api/graph"
+	"github.com/rizghz/api/models"
+	"github.com/rizghz/api/routes/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+type CountingUserMockModel struct {
+	ValidUserMockModel
+	batches int
+}
+
+func (mock *CountingUserMockModel) GetByIDs(ctx context.Context, keys []uint) []models.User {
+	mock.batches++
+	return mock.ValidUserMockModel.GetByIDs(ctx, keys)
+}
+
+type CountingBlogMockModel struct {
+	ValidBlogMockModel
+	batches int
+}
+
+func (mock *CountingBlogMockModel) GetByUsers(ctx context.Context, userIDs []uint) map[uint][]models.Blog {
+	mock.batches++
+	return mock.ValidBlogMockModel.GetByUsers(ctx, userIDs)
+}
+
+type GraphQLResponse struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+var testJwt = &configs.JwtConfig{Secret: "rahasia", Expiry: time.Hour}
+
+func newGraphQLEcho(t *testing.T, m graph.Models) *echo.Echo {
+	executor, err := graph.NewExecutor(m, graph.Limits{MaxDepth: 6, MaxComplexity: 500, ListCost: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := echo.New()
+	controller := NewGraphQLController(executor)
+	e.GET("/graphql", controller.Query(), middleware.OptionalJWT(testJwt))
+	e.POST("/graphql", controller.Query(), middleware.OptionalJWT(testJwt))
+	return e
+}
+
+func graphQLRequest(query string, userID int) *http.Request {
+	body, _ := json.Marshal(graph.Request{Query: query})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if userID != 0 {
+		token, _ := middleware.CreateToken(testJwt, userID)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	return req
+}
+
+func TestGraphQLQuery(t *testing.T) {
+	t.Run("Valid GraphQL Query (batched)", func(t *testing.T) {
+		users, blogs := &CountingUserMockModel{}, &CountingBlogMockModel{}
+		e := newGraphQLEcho(t, graph.Models{Users: users, Books: &ValidBookMockModel{}, Blogs: blogs})
+		rec, res := httptest.NewRecorder(), GraphQLResponse{}
+		e.ServeHTTP(rec, graphQLRequest(`{ blogs { title author { name blogs { title } } } }`, 0))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, res.Errors)
+		assert.Len(t, res.Data["blogs"], 3)
+		assert.Equal(t, 1, users.batches)
+		assert.Equal(t, 1, blogs.batches)
+	})
+
+	t.Run("Valid GraphQL Query (GET)", func(t *testing.T) {
+		e := newGraphQLEcho(t, graph.Models{Users: &ValidUserMockModel{}, Books: &ValidBookMockModel{}, Blogs: &ValidBlogMockModel{}})
+		rec, res := httptest.NewRecorder(), GraphQLResponse{}
+		query := url.Values{"query": {`query($id: Int!) { book(id: $id) { title } }`}, "variables": {`{"id": 2}`}}
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/graphql?"+query.Encode(), nil))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotEmpty(t, res.Data["book"])
+	})
+
+	t.Run("Invalid GraphQL Query (depth)", func(t *testing.T) {
+		e := newGraphQLEcho(t, graph.Models{Users: &ValidUserMockModel{}, Books: &ValidBookMockModel{}, Blogs: &ValidBlogMockModel{}})
+		rec, res := httptest.NewRecorder(), GraphQLResponse{}
+		e.ServeHTTP(rec, graphQLRequest(`{ users { blogs { author { blogs { author { blogs { author { name } } } } } } } }`, 0))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		if assert.Len(t, res.Errors, 1) {
+			assert.Contains(t, res.Errors[0].Message, "depth")
+		}
+	})
+
+	t.Run("Invalid GraphQL Query (complexity)", func(t *testing.T) {
+		e := newGraphQLEcho(t, graph.Models{Users: &ValidUserMockModel{}, Books: &ValidBookMockModel{}, Blogs: &ValidBlogMockModel{}})
+		rec, res := httptest.NewRecorder(), GraphQLResponse{}
+		e.ServeHTTP(rec, graphQLRequest(`{ users { blogs { title content author { blogs { title } } } } }`, 0))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		if assert.Len(t, res.Errors, 1) {
+			assert.Contains(t, res.Errors[0].Message, "complexity")
+		}
+	})
+
+	t.Run("Invalid GraphQL Query (syntax)", func(t *testing.T) {
+		e := newGraphQLEcho(t, graph.Models{Users: &ValidUserMockModel{}, Books: &ValidBookMockModel{}, Blogs: &ValidBlogMockModel{}})
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, graphQLRequest(`{ users { password } }`, 0))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestGraphQLMutation(t *testing.T) {
+	t.Run("Valid GraphQL Mutation (owner)", func(t *testing.T) {
+		e := newGraphQLEcho(t, graph.Models{Users: &ValidUserMockModel{}, Books: &ValidBookMockModel{}, Blogs: &ValidBlogMockModel{}})
+		rec, res := httptest.NewRecorder(), GraphQLResponse{}
+		e.ServeHTTP(rec, graphQLRequest(`mutation { updateBlog(id: 1, title: "Baru") { title userId } }`, 1))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, res.Errors)
+		assert.Equal(t, map[string]any{"title": "Baru", "userId": float64(1)}, res.Data["updateBlog"])
+	})
+
+	t.Run("Invalid GraphQL Mutation (unauthenticated)", func(t *testing.T) {
+		e := newGraphQLEcho(t, graph.Models{Users: &ValidUserMockModel{}, Books: &ValidBookMockModel{}, Blogs: &ValidBlogMockModel{}})
+		rec, res := httptest.NewRecorder(), GraphQLResponse{}
+		e.ServeHTTP(rec, graphQLRequest(`mutation { createBlog(title: "A", content: "B") { id } }`, 0))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		if assert.Len(t, res.Errors, 1) {
+			assert.Equal(t, "authentication required", res.Errors[0].Message)
+		}
+	})
+
+	t.Run("Invalid GraphQL Mutation (not the owner)", func(t *testing.T) {
+		e := newGraphQLEcho(t, graph.Models{Users: &ValidUserMockModel{}, Books: &ValidBookMockModel{}, Blogs: &ValidBlogMockModel{}})
+		rec, res := httptest.NewRecorder(), GraphQLResponse{}
+		e.ServeHTTP(rec, graphQLRequest(`mutation { deleteBlog(id: 1) }`, 2))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		if assert.Len(t, res.Errors, 1) {
+			assert.Equal(t, "not allowed to change this resource", res.Errors[0].Message)
+		}
+	})
+
+	t.Run("Invalid GraphQL Mutation (GET)", func(t *testing.T) {
+		e := newGraphQLEcho(t, graph.Models{Users: &ValidUserMockModel{}, Books: &ValidBookMockModel{}, Blogs: &ValidBlogMockModel{}})
+		rec := httptest.NewRecorder()
+		query := url.Values{"query": {`mutation { deleteBook(id: 1) }`}}
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/graphql?"+query.Encode(), nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Invalid GraphQL Mutation (bad token)", func(t *testing.T) {
+		e := newGraphQLEcho(t, graph.Models{Users: &ValidUserMockModel{}, Books: &ValidBookMockModel{}, Blogs: &ValidBlogMockModel{}})
+		rec, req := httptest.NewRecorder(), graphQLRequest(`{ me { name } }`, 0)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer palsu")
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestGraphQLLogin(t *testing.T) {
+	executor, err := graph.NewExecutor(graph.Models{Users: &ValidUserMockModel{}, Books: &ValidBookMockModel{}, Blogs: &ValidBlogMockModel{}},
+		graph.Limits{MaxDepth: 6, MaxComplexity: 500, ListCost: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := echo.New()
+	limiter := middleware.NewRateLimiter(&configs.RateLimitConfig{Enabled: true, Read: "100/1m", Write: "100/1m", Auth: "2/1m"},
+		middleware.NewMemoryStore())
+	e.POST("/graphql", NewGraphQLController(executor).Query(), limiter.Default(), limiter.Logins())
+	login := `%s: login(email: "a@mail.com", password: "A123") { token }`
+
+	t.Run("Valid GraphQL Login", func(t *testing.T) {
+		rec, res := httptest.NewRecorder(), GraphQLResponse{}
+		req := graphQLRequest(`mutation { `+fmt.Sprintf(login, "a")+` }`, 0)
+		req.RemoteAddr = "192.0.2.1:4321"
+		e.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Empty(t, res.Errors)
+		assert.NotNil(t, res.Data["a"])
+	})
+
+	t.Run("Invalid GraphQL Login (aliases share the auth limit)", func(t *testing.T) {
+		rec, res := httptest.NewRecorder(), GraphQLResponse{}
+		req := graphQLRequest(`mutation { `+fmt.Sprintf(login, "a")+" "+fmt.Sprintf(login, "b")+" "+fmt.Sprintf(login, "c")+` }`, 0)
+		req.RemoteAddr = "192.0.2.2:4321"
+		e.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		if assert.Len(t, res.Errors, 1) {
+			assert.Equal(t, "too many login attempts", res.Errors[0].Message)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/helpers"
+)
+
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type HealthCheckResult struct {
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration_ms"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type HealthStatus struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks,omitempty"`
+}
+
+type HealthController struct {
+	checks   []HealthCheck
+	timeout  time.Duration
+	shutdown atomic.Bool
+}
+
+type IHealthController interface {
+	Liveness() echo.HandlerFunc
+	Readiness() echo.HandlerFunc
+	Shutdown()
+}
+
+func NewHealthController(timeout time.Duration, checks ...HealthCheck) IHealthController {
+	return &HealthController{
+		checks:  checks,
+		timeout: timeout,
+	}
+}
+
+func (c *HealthController) Liveness() echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK,
+			helpers.FormatResponse("success", HealthStatus{Status: "up"}))
+	}
+}
+
+func (c *HealthController) Readiness() echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		status := HealthStatus{Status: "up", Checks: map[string]HealthCheckResult{}}
+		if c.shutdown.Load() {
+			status.Status = "shutting down"
+			return ctx.JSON(http.StatusServiceUnavailable,
+				helpers.FormatResponse("service unavailable", status))
+		}
+
+		mu, wg := sync.Mutex{}, sync.WaitGroup{}
+		for _, check := range c.checks {
+			wg.Add(1)
+			go func(check HealthCheck) {
+				defer wg.Done()
+				checkCtx, cancel := context.WithTimeout(ctx.Request().Context(), c.timeout)
+				defer cancel()
+				start := time.Now()
+				err := check.Check(checkCtx)
+				result := HealthCheckResult{
+					Status:   "up",
+					Duration: float64(time.Since(start).Microseconds()) / 1000,
+				}
+				if err != nil {
+					result.Status, result.Error = "down", err.Error()
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				status.Checks[check.Name] = result
+				if err != nil {
+					status.Status = "down"
+				}
+			}(check)
+		}
+		wg.Wait()
+
+		if status.Status != "up" {
+			return ctx.JSON(http.StatusServiceUnavailable,
+				helpers.FormatResponse("service unavailable", status))
+		}
+		return ctx.JSON(http.StatusOK,
+			helpers.FormatResponse("success", status))
+	}
+}
+
+func (c *HealthController) Shutdown() {
+	c.shutdown.Store(true)
+}
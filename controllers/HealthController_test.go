@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type HealthResponse struct {
+	Data    HealthStatus `json:"data"`
+	Message string       `json:"message"`
+}
+
+func validHealthCheck(ctx context.Context) error {
+	return nil
+}
+
+func invalidHealthCheck(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestHealthLiveness(t *testing.T) {
+	e := echo.New()
+
+	t.Run("Valid Health Liveness", func(t *testing.T) {
+		req, res := httptest.NewRequest(http.MethodGet, "/healthz", nil), HealthResponse{}
+		controller := NewHealthController(time.Second, HealthCheck{"database", invalidHealthCheck})
+		rec := httptest.NewRecorder()
+		e.GET("/healthz", controller.Liveness())
+		e.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		if assert.NoError(t, nil, controller.Liveness()) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "up", res.Data.Status)
+		}
+	})
+}
+
+func TestHealthReadiness(t *testing.T) {
+	e := echo.New()
+
+	t.Run("Valid Health Readiness", func(t *testing.T) {
+		req, res := httptest.NewRequest(http.MethodGet, "/readyz", nil), HealthResponse{}
+		controller := NewHealthController(time.Second,
+			HealthCheck{"database", validHealthCheck}, HealthCheck{"migrations", validHealthCheck})
+		rec := httptest.NewRecorder()
+		e.GET("/readyz", controller.Readiness())
+		e.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		if assert.NoError(t, nil, controller.Readiness()) {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "success", res.Message)
+			assert.Equal(t, "up", res.Data.Status)
+			assert.Len(t, res.Data.Checks, 2)
+		}
+	})
+
+	t.Run("Invalid Health Readiness (check)", func(t *testing.T) {
+		req, res := httptest.NewRequest(http.MethodGet, "/readyz", nil), HealthResponse{}
+		controller := NewHealthController(time.Second,
+			HealthCheck{"database", invalidHealthCheck}, HealthCheck{"migrations", validHealthCheck})
+		rec := httptest.NewRecorder()
+		e.GET("/readyz", controller.Readiness())
+		e.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		if assert.NoError(t, nil, controller.Readiness()) {
+			assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+			assert.Equal(t, "down", res.Data.Status)
+			assert.Equal(t, "down", res.Data.Checks["database"].Status)
+			assert.Equal(t, "connection refused", res.Data.Checks["database"].Error)
+			assert.Equal(t, "up", res.Data.Checks["migrations"].Status)
+		}
+	})
+
+	t.Run("Invalid Health Readiness (shutdown)", func(t *testing.T) {
+		req, res := httptest.NewRequest(http.MethodGet, "/readyz", nil), HealthResponse{}
+		controller := NewHealthController(time.Second, HealthCheck{"database", validHealthCheck})
+		controller.Shutdown()
+		rec := httptest.NewRecorder()
+		e.GET("/readyz", controller.Readiness())
+		e.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		if assert.NoError(t, nil, controller.Readiness()) {
+			assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+			assert.Equal(t, "shutting down", res.Data.Status)
+		}
+	})
+}
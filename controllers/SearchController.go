@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/helpers"
+	m "github.com/rizghz/api/models"
+)
+
+type SearchController struct {
+	books m.IBookModel
+	blogs m.IBlogModel
+	index m.ISearchIndex
+}
+
+type ISearchController interface {
+	Search() echo.HandlerFunc
+}
+
+// NewSearchController searches index when it is not nil and the database
+// otherwise
+func NewSearchController(books m.IBookModel, blogs m.IBlogModel, index m.ISearchIndex) ISearchController {
+	return &SearchController{
+		books: books,
+		blogs: blogs,
+		index: index,
+	}
+}
+
+// Search ranks books and blogs together. Without an index each resource is
+// asked for every hit up to the end of the page before they are merged.
+func (c *SearchController) Search() echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		query, err := parseSearch(ctx)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest,
+				helpers.FormatResponse(err.Error(), nil))
+		}
+		if c.index != nil {
+			result := c.index.Search(ctx.Request().Context(), query)
+			if result == nil {
+				return ctx.JSON(http.StatusInternalServerError,
+					helpers.FormatResponse("server error", nil))
+			}
+			return ctx.JSON(http.StatusOK,
+				helpers.FormatResponse("success", result))
+		}
+		if len(query.Filters) > 0 {
+			return ctx.JSON(http.StatusBadRequest,
+				helpers.FormatResponse(errNoIndex.Error(), nil))
+		}
+		window := m.SearchQuery{Text: query.Text, Page: 1, PerPage: query.Page * query.PerPage}
+		books := c.books.Search(ctx.Request().Context(), window)
+		blogs := c.blogs.Search(ctx.Request().Context(), window)
+		if books == nil || blogs == nil {
+			return ctx.JSON(http.StatusInternalServerError,
+				helpers.FormatResponse("server error", nil))
+		}
+		return ctx.JSON(http.StatusOK,
+			helpers.FormatResponse("success", m.MergeResults(query, books, blogs)))
+	}
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type ValidSearchIndexMock struct {
+	query models.SearchQuery
+}
+
+func (mock *ValidSearchIndexMock) Search(ctx context.Context, query models.SearchQuery) *models.SearchResult {
+	mock.query = query
+	return &models.SearchResult{
+		Hits:    []models.SearchHit{{Resource: "books", ID: 1, Score: 1, Snippet: "<mark>Go</mark>"}},
+		Total:   1,
+		Page:    query.Page,
+		PerPage: query.PerPage,
+		Facets:  map[string][]models.Facet{"author": {{Value: "Jon Bodner", Count: 1}}},
+	}
+}
+
+type SearchResponse struct {
+	Data    models.SearchResult `json:"data"`
+	Message string              `json:"message"`
+}
+
+func TestSearch(t *testing.T) {
+	e := echo.New()
+	e.GET("/search", NewSearchController(&ValidBookMockModel{}, &ValidBlogMockModel{}, nil).Search())
+
+	t.Run("Valid Search", func(t *testing.T) {
+		rec, res := httptest.NewRecorder(), SearchResponse{}
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?q=golang", nil))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.EqualValues(t, 6, res.Data.Total)
+		if assert.Len(t, res.Data.Hits, 6) {
+			for i := 1; i < len(res.Data.Hits); i++ {
+				assert.GreaterOrEqual(t, res.Data.Hits[i-1].Score, res.Data.Hits[i].Score)
+			}
+		}
+	})
+
+	t.Run("Valid Search Page", func(t *testing.T) {
+		rec, res := httptest.NewRecorder(), SearchResponse{}
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?q=golang&page=3&per_page=2", nil))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 3, res.Data.Page)
+		if assert.Len(t, res.Data.Hits, 2) {
+			assert.EqualValues(t, 1, res.Data.Hits[0].Score)
+		}
+	})
+
+	t.Run("Invalid Search (query)", func(t *testing.T) {
+		rec, res := httptest.NewRecorder(), SearchResponse{}
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search", nil))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "missing search query", res.Message)
+	})
+
+	t.Run("Invalid Search (per_page)", func(t *testing.T) {
+		rec, res := httptest.NewRecorder(), SearchResponse{}
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?q=golang&per_page=500", nil))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "per_page is over 100", res.Message)
+	})
+
+	t.Run("Valid Search Index", func(t *testing.T) {
+		index := &ValidSearchIndexMock{}
+		e := echo.New()
+		e.GET("/search", NewSearchController(&InvalidBookMockModel{}, &InvalidBlogMockModel{}, index).Search())
+		rec, res := httptest.NewRecorder(), SearchResponse{}
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?q=golang&author=Jon+Bodner", nil))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, map[string]string{"author": "Jon Bodner"}, index.query.Filters)
+		assert.Len(t, res.Data.Hits, 1)
+		assert.Equal(t, []models.Facet{{Value: "Jon Bodner", Count: 1}}, res.Data.Facets["author"])
+	})
+
+	t.Run("Invalid Search (filters without index)", func(t *testing.T) {
+		rec, res := httptest.NewRecorder(), SearchResponse{}
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?q=golang&year=2023", nil))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "filters need the search index", res.Message)
+	})
+
+	t.Run("Invalid Search (server)", func(t *testing.T) {
+		e := echo.New()
+		e.GET("/search", NewSearchController(&ValidBookMockModel{}, &InvalidBlogMockModel{}, nil).Search())
+		rec, res := httptest.NewRecorder(), SearchResponse{}
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?q=golang", nil))
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "server error", res.Message)
+	})
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/labstack/echo/v4"
 	"github.com/rizghz/api/helpers"
+	"github.com/rizghz/api/metrics"
 	m "github.com/rizghz/api/models"
 )
 
@@ -30,9 +31,14 @@ func NewUserController(model m.IUserModel) IUserController {
 
 func (c *UserController) Index() echo.HandlerFunc {
 	return func(ctx echo.Context) error {
-		data := c.model.Get()
+		selection, err := parseSelection(ctx, m.UserFields, nil)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest,
+				helpers.FormatResponse(err.Error(), nil))
+		}
+		data := c.model.Get(m.WithSelection(ctx.Request().Context(), selection))
 		return ctx.JSON(http.StatusOK,
-			helpers.FormatResponse("success", data))
+			helpers.FormatResponse("success", sparse(data, selection, nil)))
 	}
 }
 
@@ -43,9 +49,14 @@ func (c *UserController) Observe() echo.HandlerFunc {
 			return ctx.JSON(http.StatusBadRequest,
 				helpers.FormatResponse("invalid user id", nil))
 		}
-		data := c.model.Find(&id)
+		selection, err := parseSelection(ctx, m.UserFields, m.UserRelations)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest,
+				helpers.FormatResponse(err.Error(), nil))
+		}
+		data := c.model.Find(m.WithSelection(ctx.Request().Context(), selection), &id)
 		return ctx.JSON(http.StatusOK,
-			helpers.FormatResponse("success", data))
+			helpers.FormatResponse("success", sparse(data, selection, m.UserRelations)))
 	}
 }
 
@@ -56,7 +67,7 @@ func (c *UserController) Store() echo.HandlerFunc {
 			return ctx.JSON(http.StatusBadRequest,
 				helpers.FormatResponse("invalid user data", nil))
 		}
-		if _, data := c.model.Create(&user); data != nil {
+		if _, data := c.model.Create(ctx.Request().Context(), &user); data != nil {
 			return ctx.JSON(http.StatusCreated,
 				helpers.FormatResponse("success", data))
 		}
@@ -78,7 +89,7 @@ func (c *UserController) Edit() echo.HandlerFunc {
 			return ctx.JSON(http.StatusBadRequest,
 				helpers.FormatResponse("invalid user data", nil))
 		}
-		if _, data := c.model.Update(&user); data != nil {
+		if _, data := c.model.Update(ctx.Request().Context(), &user); data != nil {
 			return ctx.JSON(http.StatusCreated,
 				helpers.FormatResponse("success", data))
 		}
@@ -94,7 +105,7 @@ func (c *UserController) Destroy() echo.HandlerFunc {
 			return ctx.JSON(http.StatusBadRequest,
 				helpers.FormatResponse("invalid user id", nil))
 		}
-		if res := c.model.Delete(&id); !res {
+		if res := c.model.Delete(ctx.Request().Context(), &id); !res {
 			return ctx.JSON(http.StatusInternalServerError,
 				helpers.FormatResponse("server error", nil))
 		}
@@ -109,10 +120,12 @@ func (c *UserController) Login() echo.HandlerFunc {
 			return ctx.JSON(http.StatusBadRequest,
 				helpers.FormatResponse("invalid user data", nil))
 		}
-		res, err := c.model.Check(&user)
+		res, err := c.model.Check(ctx.Request().Context(), &user)
 		if err != nil {
+			metrics.LoginAttempts.WithLabelValues("failure").Inc()
 			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
+		metrics.LoginAttempts.WithLabelValues("success").Inc()
 		return ctx.JSON(http.StatusOK,
 			helpers.FormatResponse("success", res))
 	}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -9,71 +10,80 @@ import (
 	"testing"
 
 	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rizghz/api/factories"
+	"github.com/rizghz/api/metrics"
 	"github.com/rizghz/api/models"
 	"github.com/stretchr/testify/assert"
 )
 
 type ValidUserMockModel struct{}
 
-func (mock *ValidUserMockModel) Get() []models.User {
-	return []models.User{
-		{Name: "User A", Email: "a@mail.com", Password: "A123"},
-		{Name: "User B", Email: "b@mail.com", Password: "B123"},
-		{Name: "User C", Email: "c@mail.com", Password: "C123"},
-	}
+func (mock *ValidUserMockModel) Get(ctx context.Context) []models.User {
+	return factories.New(1).Users(3)
 }
 
-func (mock *ValidUserMockModel) Find(key *int) *models.User {
-	return &models.User{
-		Name:     "User A",
-		Email:    "a@mail.com",
-		Password: "A123",
-	}
+func (mock *ValidUserMockModel) Find(ctx context.Context, key *int) *models.User {
+	user := factories.New(int64(*key)).User()
+	return &user
 }
 
-func (mock *ValidUserMockModel) Create(user *models.User) (bool, *models.User) {
+func (mock *ValidUserMockModel) Create(ctx context.Context, user *models.User) (bool, *models.User) {
 	return true, user
 }
 
-func (mock *ValidUserMockModel) Update(user *models.User) (bool, *models.User) {
+func (mock *ValidUserMockModel) Update(ctx context.Context, user *models.User) (bool, *models.User) {
 	return true, user
 }
 
-func (mock *ValidUserMockModel) Delete(key *int) bool {
+func (mock *ValidUserMockModel) Delete(ctx context.Context, key *int) bool {
 	return true
 }
 
-func (mock *ValidUserMockModel) Check(user *models.User) (*models.User, error) {
+func (mock *ValidUserMockModel) Check(ctx context.Context, user *models.User) (*models.User, error) {
 	user.Token = "falsidfk2j3r123klflasjf"
 	return user, nil
 }
 
+func (mock *ValidUserMockModel) GetByIDs(ctx context.Context, keys []uint) []models.User {
+	users := make([]models.User, len(keys))
+	for i, key := range keys {
+		users[i] = factories.New(int64(key)).User()
+		users[i].ID = key
+	}
+	return users
+}
+
 type InvalidUserMockModel struct{}
 
-func (mock *InvalidUserMockModel) Get() []models.User {
+func (mock *InvalidUserMockModel) Get(ctx context.Context) []models.User {
 	return nil
 }
 
-func (mock *InvalidUserMockModel) Find(key *int) *models.User {
+func (mock *InvalidUserMockModel) Find(ctx context.Context, key *int) *models.User {
 	return nil
 }
 
-func (mock *InvalidUserMockModel) Create(user *models.User) (bool, *models.User) {
+func (mock *InvalidUserMockModel) Create(ctx context.Context, user *models.User) (bool, *models.User) {
 	return false, nil
 }
 
-func (mock *InvalidUserMockModel) Update(user *models.User) (bool, *models.User) {
+func (mock *InvalidUserMockModel) Update(ctx context.Context, user *models.User) (bool, *models.User) {
 	return false, nil
 }
 
-func (mock *InvalidUserMockModel) Delete(key *int) bool {
+func (mock *InvalidUserMockModel) Delete(ctx context.Context, key *int) bool {
 	return false
 }
 
-func (mock *InvalidUserMockModel) Check(user *models.User) (*models.User, error) {
+func (mock *InvalidUserMockModel) Check(ctx context.Context, user *models.User) (*models.User, error) {
 	return nil, errors.New("Invalid")
 }
 
+func (mock *InvalidUserMockModel) GetByIDs(ctx context.Context, keys []uint) []models.User {
+	return nil
+}
+
 type UserResponseA struct {
 	Data    []models.User `json:"data"`
 	Message string        `json:"message"`
@@ -160,6 +170,26 @@ func TestUserObserve(t *testing.T) {
 	})
 }
 
+func TestUserSelection(t *testing.T) {
+	e := echo.New()
+	controller := NewUserController(&ValidUserMockModel{})
+	e.GET("/users/:id", controller.Observe())
+
+	t.Run("Invalid User Selection (password)", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/1?fields=name,password", nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "unknown field password")
+	})
+
+	t.Run("Invalid User Selection (include)", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/1?include=books", nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "cannot include books")
+	})
+}
+
 func TestUserStore(t *testing.T) {
 	e := echo.New()
 	data := []byte(`{"name":"User Baru", "email":"baru@mail.com", "password":"Baru321"}`)
@@ -317,7 +347,7 @@ func TestUserLogin(t *testing.T) {
 		req, res := httptest.NewRequest(http.MethodGet, "/login", bytes.NewReader(data)), UserResponseB{}
 		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 		controller := NewUserController(&ValidUserMockModel{})
-		rec := httptest.NewRecorder()
+		rec, before := httptest.NewRecorder(), testutil.ToFloat64(metrics.LoginAttempts.WithLabelValues("success"))
 		e.GET("/login", controller.Login())
 		e.ServeHTTP(rec, req)
 		json.Unmarshal(rec.Body.Bytes(), &res)
@@ -325,6 +355,7 @@ func TestUserLogin(t *testing.T) {
 			assert.Equal(t, http.StatusOK, rec.Code)
 			assert.Equal(t, "success", res.Message)
 			assert.NotEmpty(t, res.Data.Token)
+			assert.Equal(t, before+1, testutil.ToFloat64(metrics.LoginAttempts.WithLabelValues("success")))
 		}
 	})
 
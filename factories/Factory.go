@@ -0,0 +1,136 @@
+package factories
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/rizghz/api/models"
+)
+
+var (
+	firstNames = []string{
+		"Adi", "Budi", "Citra", "Dewi", "Eko", "Fitri", "Gilang", "Hana",
+		"Indra", "Joko", "Kartika", "Lestari", "Maya", "Nanda", "Oki", "Putri",
+		"Rizki", "Sari", "Taufik", "Wulan", "Yusuf", "Zahra",
+	}
+	lastNames = []string{
+		"Pratama", "Saputra", "Wijaya", "Kusuma", "Santoso", "Hidayat",
+		"Nugroho", "Permata", "Lestari", "Utami", "Siregar", "Nasution",
+	}
+	domains    = []string{"mail.com", "example.com", "example.org", "contoh.id"}
+	publishers = []string{
+		"Gramedia Pustaka Utama", "Mizan", "Erlangga", "Bentang Pustaka",
+		"Kepustakaan Populer Gramedia", "Republika", "Grasindo", "Elex Media Komputindo",
+	}
+	subjects = []string{
+		"Laut", "Bumi", "Hujan", "Senja", "Pelangi", "Kota", "Rumah", "Jalan",
+		"Mimpi", "Waktu", "Angin", "Bulan", "Matahari", "Gunung", "Sungai",
+	}
+	adjectives = []string{
+		"Bercerita", "yang Hilang", "Terakhir", "di Ujung Jalan", "Tanpa Nama",
+		"Merah", "Biru", "Sunyi", "Abadi", "Pertama",
+	}
+	words = []string{
+		"belajar", "menulis", "kode", "server", "database", "aplikasi", "hari",
+		"ini", "kita", "akan", "membahas", "cara", "membangun", "sebuah", "api",
+		"dengan", "golang", "yang", "cepat", "dan", "aman", "untuk", "pengguna",
+	}
+)
+
+type Factory struct {
+	rand  *rand.Rand
+	count int
+}
+
+func New(seed int64) *Factory {
+	return &Factory{
+		rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (f *Factory) User() models.User {
+	f.count++
+	first, last := f.pick(firstNames), f.pick(lastNames)
+	return models.User{
+		Name:     first + " " + last,
+		Email:    fmt.Sprintf("%s.%s%d@%s", strings.ToLower(first), strings.ToLower(last), f.count, f.pick(domains)),
+		Password: fmt.Sprintf("%s%04d", first, f.rand.Intn(10000)),
+	}
+}
+
+func (f *Factory) Users(n int) []models.User {
+	users := make([]models.User, n)
+	for i := range users {
+		users[i] = f.User()
+	}
+	return users
+}
+
+func (f *Factory) Book() models.Book {
+	return models.Book{
+		ISBN:      f.isbn(),
+		Title:     f.pick(subjects) + " " + f.pick(adjectives),
+		Author:    f.pick(firstNames) + " " + f.pick(lastNames),
+		Publisher: f.pick(publishers),
+	}
+}
+
+func (f *Factory) Books(n int) []models.Book {
+	books := make([]models.Book, n)
+	for i := range books {
+		books[i] = f.Book()
+	}
+	return books
+}
+
+func (f *Factory) Blog(userID uint) models.Blog {
+	title := f.sentence(3 + f.rand.Intn(4))
+	paragraphs := make([]string, 1+f.rand.Intn(3))
+	for i := range paragraphs {
+		sentences := make([]string, 2+f.rand.Intn(4))
+		for j := range sentences {
+			sentences[j] = f.sentence(6+f.rand.Intn(8)) + "."
+		}
+		paragraphs[i] = strings.Join(sentences, " ")
+	}
+	return models.Blog{
+		Title:   strings.TrimSuffix(title, "."),
+		Content: strings.Join(paragraphs, "\n\n"),
+		UserID:  userID,
+	}
+}
+
+func (f *Factory) Blogs(n int, userID uint) []models.Blog {
+	blogs := make([]models.Blog, n)
+	for i := range blogs {
+		blogs[i] = f.Blog(userID)
+	}
+	return blogs
+}
+
+func (f *Factory) pick(list []string) string {
+	return list[f.rand.Intn(len(list))]
+}
+
+func (f *Factory) sentence(n int) string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = f.pick(words)
+	}
+	sentence := strings.Join(parts, " ")
+	return strings.ToUpper(sentence[:1]) + sentence[1:]
+}
+
+func (f *Factory) isbn() string {
+	digits := fmt.Sprintf("978602%06d", f.rand.Intn(1000000))
+	sum := 0
+	for i, d := range digits {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(d-'0') * weight
+	}
+	return fmt.Sprintf("%s%d", digits, (10-sum%10)%10)
+}
@@ -0,0 +1,38 @@
+package factories
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFactory(t *testing.T) {
+	t.Run("Deterministic Factory", func(t *testing.T) {
+		assert.Equal(t, New(42).Users(5), New(42).Users(5))
+		assert.Equal(t, New(42).Books(5), New(42).Books(5))
+		assert.Equal(t, New(42).Blogs(5, 1), New(42).Blogs(5, 1))
+		assert.NotEqual(t, New(42).Users(5), New(43).Users(5))
+	})
+
+	t.Run("Valid Factory Data", func(t *testing.T) {
+		f, emails := New(1), map[string]bool{}
+		for _, user := range f.Users(50) {
+			assert.NotEmpty(t, user.Name)
+			assert.False(t, emails[user.Email])
+			emails[user.Email] = true
+		}
+		for _, book := range f.Books(50) {
+			sum := 0
+			for i, d := range book.ISBN {
+				sum += int(d-'0') * (1 + 2*(i%2))
+			}
+			assert.Len(t, book.ISBN, 13)
+			assert.Zero(t, sum%10)
+		}
+		for _, blog := range f.Blogs(5, 7) {
+			assert.NotEmpty(t, blog.Title)
+			assert.NotEmpty(t, blog.Content)
+			assert.Equal(t, uint(7), blog.UserID)
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package factories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rizghz/api/models"
+)
+
+type Seeder struct {
+	Users        int
+	BlogsPerUser int
+	Books        int
+}
+
+func (s *Seeder) Run(ctx context.Context, f *Factory, users models.IUserModel, books models.IBookModel) error {
+	for i := 0; i < s.Users; i++ {
+		user := f.User()
+		user.Blogs = f.Blogs(s.BlogsPerUser, 0)
+		if ok, _ := users.Create(ctx, &user); !ok {
+			return errors.New("[err]: failed to seed users")
+		}
+	}
+	for i := 0; i < s.Books; i++ {
+		book := f.Book()
+		if ok, _ := books.Create(ctx, &book); !ok {
+			return errors.New("[err]: failed to seed books")
+		}
+	}
+	return nil
+}
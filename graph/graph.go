@@ -0,0 +1,99 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/rizghz/api/models"
+)
+
+type Request struct {
+	Query         string                 `json:"query" query:"query"`
+	OperationName string                 `json:"operationName" query:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	// ReadOnly rejects mutations, as for requests sent with GET
+	ReadOnly bool `json:"-"`
+}
+
+type Executor struct {
+	schema graphql.Schema
+	models Models
+	limits Limits
+}
+
+func NewExecutor(m Models, limits Limits) (*Executor, error) {
+	schema, err := NewSchema(m)
+	if err != nil {
+		return nil, err
+	}
+	return &Executor{schema: schema, models: m, limits: limits}, nil
+}
+
+// Do parses, validates and runs a request. Queries that exceed the depth or
+// complexity limits are rejected before any resolver runs.
+func (e *Executor) Do(ctx context.Context, req Request) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(req.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if validation := graphql.ValidateDocument(&e.schema, doc, graphql.SpecifiedRules); !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+	if operation := operationOf(doc, req.OperationName); req.ReadOnly && operation != nil && operation.Operation == ast.OperationTypeMutation {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(errors.New("mutations must be sent with POST"))}
+	}
+	if err := e.limits.check(&e.schema, doc, req.OperationName); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        e.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoaders(ctx, e.models),
+	})
+}
+
+type loaders struct {
+	users *Loader[uint, *models.User]
+	blogs *Loader[uint, []models.Blog]
+}
+
+type loadersKey struct{}
+
+// withLoaders gives every request its own loaders so nothing is cached
+// across requests
+func withLoaders(ctx context.Context, m Models) context.Context {
+	l := &loaders{
+		users: NewLoader(func(keys []uint) map[uint]*models.User {
+			users := map[uint]*models.User{}
+			for _, user := range m.Users.GetByIDs(ctx, keys) {
+				user := user
+				users[user.ID] = &user
+			}
+			return users
+		}),
+		blogs: NewLoader(func(keys []uint) map[uint][]models.Blog {
+			blogs := m.Blogs.GetByUsers(ctx, keys)
+			for _, key := range keys {
+				if blogs != nil && blogs[key] == nil {
+					blogs[key] = []models.Blog{}
+				}
+			}
+			return blogs
+		}),
+	}
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersOf(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
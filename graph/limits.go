@@ -0,0 +1,108 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+type Limits struct {
+	MaxDepth      int
+	MaxComplexity int
+	ListCost      int
+}
+
+// measure walks the selected operation against the schema and returns its
+// depth and cost. A field costs one plus the cost of its selection, which is
+// multiplied by ListCost when the field returns a list. Introspection fields
+// are bounded by the schema itself and are not counted.
+func (l Limits) measure(schema *graphql.Schema, doc *ast.Document, operationName string) (int, int) {
+	operation := operationOf(doc, operationName)
+	if operation == nil {
+		return 0, 0
+	}
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, definition := range doc.Definitions {
+		if fragment, found := definition.(*ast.FragmentDefinition); found {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+	root := schema.QueryType()
+	if operation.Operation == ast.OperationTypeMutation {
+		root = schema.MutationType()
+	}
+
+	var walk func(parent graphql.Type, set *ast.SelectionSet) (int, int)
+	walk = func(parent graphql.Type, set *ast.SelectionSet) (int, int) {
+		depth, cost := 0, 0
+		if set == nil {
+			return depth, cost
+		}
+		object, _ := parent.(*graphql.Object)
+		for _, selection := range set.Selections {
+			var d, c int
+			switch selection := selection.(type) {
+			case *ast.Field:
+				if object == nil || strings.HasPrefix(selection.Name.Value, "__") {
+					continue
+				}
+				field, found := object.Fields()[selection.Name.Value]
+				if !found {
+					continue
+				}
+				d, c = walk(graphql.GetNamed(field.Type).(graphql.Type), selection.SelectionSet)
+				if isList(field.Type) {
+					c *= l.ListCost
+				}
+				d, c = d+1, c+1
+			case *ast.InlineFragment:
+				next := parent
+				if selection.TypeCondition != nil {
+					next = schema.Type(selection.TypeCondition.Name.Value)
+				}
+				d, c = walk(next, selection.SelectionSet)
+			case *ast.FragmentSpread:
+				if fragment, found := fragments[selection.Name.Value]; found {
+					d, c = walk(schema.Type(fragment.TypeCondition.Name.Value), fragment.SelectionSet)
+				}
+			}
+			if d > depth {
+				depth = d
+			}
+			cost += c
+		}
+		return depth, cost
+	}
+	return walk(root, operation.SelectionSet)
+}
+
+func (l Limits) check(schema *graphql.Schema, doc *ast.Document, operationName string) error {
+	depth, cost := l.measure(schema, doc, operationName)
+	if l.MaxDepth > 0 && depth > l.MaxDepth {
+		return fmt.Errorf("query depth %d exceeds the maximum of %d", depth, l.MaxDepth)
+	}
+	if l.MaxComplexity > 0 && cost > l.MaxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the maximum of %d", cost, l.MaxComplexity)
+	}
+	return nil
+}
+
+func isList(t graphql.Type) bool {
+	if nonNull, found := t.(*graphql.NonNull); found {
+		t = nonNull.OfType
+	}
+	_, found := t.(*graphql.List)
+	return found
+}
+
+func operationOf(doc *ast.Document, operationName string) *ast.OperationDefinition {
+	for _, definition := range doc.Definitions {
+		operation, found := definition.(*ast.OperationDefinition)
+		if found && (operationName == "" || operation.Name != nil && operation.Name.Value == operationName) {
+			return operation
+		}
+	}
+	return nil
+}
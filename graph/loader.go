@@ -0,0 +1,60 @@
+package graph
+
+import "sync"
+
+// Loader collects the keys requested while a level of the query is being
+// resolved and fetches them with a single call once the first value is read.
+// graphql-go resolves thunks breadth first, so every key of a level is known
+// before any of them is needed.
+type Loader[K comparable, V any] struct {
+	mu      sync.Mutex
+	fetch   func(keys []K) map[K]V
+	pending []K
+	values  map[K]V
+	loaded  map[K]bool
+}
+
+func NewLoader[K comparable, V any](fetch func(keys []K) map[K]V) *Loader[K, V] {
+	return &Loader[K, V]{
+		fetch:  fetch,
+		values: map[K]V{},
+		loaded: map[K]bool{},
+	}
+}
+
+func (l *Loader[K, V]) Load(key K) func() (interface{}, error) {
+	l.mu.Lock()
+	if !l.loaded[key] {
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+	return func() (interface{}, error) {
+		l.dispatch()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.values[key], nil
+	}
+}
+
+func (l *Loader[K, V]) dispatch() {
+	l.mu.Lock()
+	keys := []K{}
+	seen := map[K]bool{}
+	for _, key := range l.pending {
+		if !l.loaded[key] && !seen[key] {
+			keys, seen[key] = append(keys, key), true
+		}
+	}
+	l.pending = nil
+	l.mu.Unlock()
+	if len(keys) == 0 {
+		return
+	}
+
+	values := l.fetch(keys)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		l.values[key], l.loaded[key] = values[key], true
+	}
+}
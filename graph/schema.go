@@ -0,0 +1,366 @@
+package graph
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/graphql-go/graphql"
+	"github.com/rizghz/api/helpers"
+	"github.com/rizghz/api/metrics"
+	"github.com/rizghz/api/models"
+	"gorm.io/gorm"
+)
+
+var (
+	errUnauthenticated = errors.New("authentication required")
+	errForbidden       = errors.New("not allowed to change this resource")
+	errNotFound        = errors.New("resource not found")
+	errServer          = errors.New("server error")
+)
+
+type Models struct {
+	Users models.IUserModel
+	Books models.IBookModel
+	Blogs models.IBlogModel
+}
+
+type resolver struct {
+	Models
+}
+
+func NewSchema(m Models) (graphql.Schema, error) {
+	r := &resolver{Models: m}
+
+	user := graphql.NewObject(graphql.ObjectConfig{Name: "User", Fields: graphql.Fields{}})
+	blog := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Blog",
+		Fields: withModel(graphql.Fields{
+			"title":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"content": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"userId": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return int(blogOf(p.Source).UserID), nil
+			}},
+			"author": &graphql.Field{Type: user, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return loadersOf(p.Context).users.Load(blogOf(p.Source).UserID), nil
+			}},
+		}),
+	})
+	// users and blogs refer to each other, so the user fields are added last
+	for name, field := range withModel(graphql.Fields{
+		"name":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"email": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"blogs": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(blog))), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return loadersOf(p.Context).blogs.Load(userOf(p.Source).ID), nil
+		}},
+	}) {
+		field.Name = name
+		user.AddFieldConfig(name, field)
+	}
+	book := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Book",
+		Fields: withModel(graphql.Fields{
+			"isbn":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"title":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"author":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"publisher": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		}),
+	})
+	auth := graphql.NewObject(graphql.ObjectConfig{
+		Name: "AuthPayload",
+		Fields: graphql.Fields{
+			"token": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"user":  &graphql.Field{Type: graphql.NewNonNull(user)},
+		},
+	})
+
+	id := graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)}}
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me":    &graphql.Field{Type: user, Resolve: r.me},
+			"users": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(user))), Resolve: r.users},
+			"user":  &graphql.Field{Type: user, Args: id, Resolve: r.user},
+			"books": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(book))), Resolve: r.books},
+			"book":  &graphql.Field{Type: book, Args: id, Resolve: r.book},
+			"blogs": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(blog))), Resolve: r.blogs},
+			"blog":  &graphql.Field{Type: blog, Args: id, Resolve: r.blog},
+		},
+	})
+
+	userArgs := arguments(true, "name", "email", "password")
+	bookArgs := arguments(true, "isbn", "title", "author", "publisher")
+	blogArgs := arguments(true, "title", "content")
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"login":      &graphql.Field{Type: graphql.NewNonNull(auth), Args: arguments(true, "email", "password"), Resolve: r.login},
+			"createUser": &graphql.Field{Type: graphql.NewNonNull(user), Args: userArgs, Resolve: r.createUser},
+			"updateUser": &graphql.Field{Type: graphql.NewNonNull(user), Args: withID(arguments(false, "name", "email", "password")), Resolve: r.updateUser},
+			"deleteUser": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Args: id, Resolve: r.deleteUser},
+			"createBook": &graphql.Field{Type: graphql.NewNonNull(book), Args: bookArgs, Resolve: r.createBook},
+			"updateBook": &graphql.Field{Type: graphql.NewNonNull(book), Args: withID(arguments(false, "isbn", "title", "author", "publisher")), Resolve: r.updateBook},
+			"deleteBook": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Args: id, Resolve: r.deleteBook},
+			"createBlog": &graphql.Field{Type: graphql.NewNonNull(blog), Args: blogArgs, Resolve: r.createBlog},
+			"updateBlog": &graphql.Field{Type: graphql.NewNonNull(blog), Args: withID(arguments(false, "title", "content")), Resolve: r.updateBlog},
+			"deleteBlog": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Args: id, Resolve: r.deleteBlog},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+// withModel adds the columns of the embedded gorm.Model to the fields
+func withModel(fields graphql.Fields) graphql.Fields {
+	model := func(p graphql.ResolveParams) gorm.Model {
+		return reflect.Indirect(reflect.ValueOf(p.Source)).FieldByName("Model").Interface().(gorm.Model)
+	}
+	fields["id"] = &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return int(model(p).ID), nil
+	}}
+	fields["createdAt"] = &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return model(p).CreatedAt, nil
+	}}
+	fields["updatedAt"] = &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return model(p).UpdatedAt, nil
+	}}
+	return fields
+}
+
+func arguments(required bool, names ...string) graphql.FieldConfigArgument {
+	args := graphql.FieldConfigArgument{}
+	for _, name := range names {
+		var t graphql.Input = graphql.String
+		if required {
+			t = graphql.NewNonNull(t)
+		}
+		args[name] = &graphql.ArgumentConfig{Type: t}
+	}
+	return args
+}
+
+func withID(args graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args["id"] = &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)}
+	return args
+}
+
+func userOf(source interface{}) *models.User {
+	if user, found := source.(models.User); found {
+		return &user
+	}
+	return source.(*models.User)
+}
+
+func blogOf(source interface{}) *models.Blog {
+	if blog, found := source.(models.Blog); found {
+		return &blog
+	}
+	return source.(*models.Blog)
+}
+
+// set copies the given string arguments onto the fields they are named after
+func set(args map[string]interface{}, fields map[string]*string) {
+	for name, field := range fields {
+		if value, found := args[name].(string); found {
+			*field = value
+		}
+	}
+}
+
+func (r *resolver) me(p graphql.ResolveParams) (interface{}, error) {
+	viewer := helpers.UserID(p.Context)
+	if viewer == 0 {
+		return nil, errUnauthenticated
+	}
+	return r.Users.Find(p.Context, &viewer), nil
+}
+
+func (r *resolver) users(p graphql.ResolveParams) (interface{}, error) {
+	return r.Users.Get(p.Context), nil
+}
+
+func (r *resolver) user(p graphql.ResolveParams) (interface{}, error) {
+	id := p.Args["id"].(int)
+	return r.Users.Find(p.Context, &id), nil
+}
+
+func (r *resolver) books(p graphql.ResolveParams) (interface{}, error) {
+	return r.Books.Get(p.Context), nil
+}
+
+func (r *resolver) book(p graphql.ResolveParams) (interface{}, error) {
+	id := p.Args["id"].(int)
+	return r.Books.Find(p.Context, &id), nil
+}
+
+func (r *resolver) blogs(p graphql.ResolveParams) (interface{}, error) {
+	return r.Blogs.Get(p.Context), nil
+}
+
+func (r *resolver) blog(p graphql.ResolveParams) (interface{}, error) {
+	id := p.Args["id"].(int)
+	return r.Blogs.Find(p.Context, &id), nil
+}
+
+func (r *resolver) login(p graphql.ResolveParams) (interface{}, error) {
+	// every aliased login of a document counts as an attempt
+	if !helpers.AllowLogin(p.Context) {
+		metrics.LoginAttempts.WithLabelValues("limited").Inc()
+		return nil, errors.New("too many login attempts")
+	}
+	user := models.User{}
+	set(p.Args, map[string]*string{"email": &user.Email, "password": &user.Password})
+	res, err := r.Users.Check(p.Context, &user)
+	if err != nil {
+		metrics.LoginAttempts.WithLabelValues("failure").Inc()
+		return nil, errors.New("invalid email or password")
+	}
+	metrics.LoginAttempts.WithLabelValues("success").Inc()
+	return map[string]interface{}{"token": res.Token, "user": res}, nil
+}
+
+func (r *resolver) createUser(p graphql.ResolveParams) (interface{}, error) {
+	user := models.User{}
+	set(p.Args, map[string]*string{"name": &user.Name, "email": &user.Email, "password": &user.Password})
+	if _, data := r.Users.Create(p.Context, &user); data != nil {
+		return data, nil
+	}
+	return nil, errServer
+}
+
+// owned returns the id argument once the viewer may change the resource
+// owned by owner
+func owned(p graphql.ResolveParams, owner func(id int) (uint, bool)) (int, error) {
+	viewer := helpers.UserID(p.Context)
+	if viewer == 0 {
+		return 0, errUnauthenticated
+	}
+	id := p.Args["id"].(int)
+	userID, found := owner(id)
+	if !found {
+		return 0, errNotFound
+	}
+	if int(userID) != viewer {
+		return 0, errForbidden
+	}
+	return id, nil
+}
+
+func (r *resolver) updateUser(p graphql.ResolveParams) (interface{}, error) {
+	var user *models.User
+	if _, err := owned(p, func(id int) (uint, bool) {
+		if user = r.Users.Find(p.Context, &id); user == nil {
+			return 0, false
+		}
+		return user.ID, true
+	}); err != nil {
+		return nil, err
+	}
+	set(p.Args, map[string]*string{"name": &user.Name, "email": &user.Email, "password": &user.Password})
+	if _, data := r.Users.Update(p.Context, user); data != nil {
+		return data, nil
+	}
+	return nil, errServer
+}
+
+func (r *resolver) deleteUser(p graphql.ResolveParams) (interface{}, error) {
+	id, err := owned(p, func(id int) (uint, bool) {
+		user := r.Users.Find(p.Context, &id)
+		if user == nil {
+			return 0, false
+		}
+		return user.ID, true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !r.Users.Delete(p.Context, &id) {
+		return nil, errServer
+	}
+	return true, nil
+}
+
+func (r *resolver) createBook(p graphql.ResolveParams) (interface{}, error) {
+	if helpers.UserID(p.Context) == 0 {
+		return nil, errUnauthenticated
+	}
+	book := models.Book{}
+	set(p.Args, map[string]*string{"isbn": &book.ISBN, "title": &book.Title, "author": &book.Author, "publisher": &book.Publisher})
+	if _, data := r.Books.Create(p.Context, &book); data != nil {
+		return data, nil
+	}
+	return nil, errServer
+}
+
+func (r *resolver) updateBook(p graphql.ResolveParams) (interface{}, error) {
+	if helpers.UserID(p.Context) == 0 {
+		return nil, errUnauthenticated
+	}
+	id := p.Args["id"].(int)
+	book := r.Books.Find(p.Context, &id)
+	if book == nil {
+		return nil, errNotFound
+	}
+	set(p.Args, map[string]*string{"isbn": &book.ISBN, "title": &book.Title, "author": &book.Author, "publisher": &book.Publisher})
+	if _, data := r.Books.Update(p.Context, book); data != nil {
+		return data, nil
+	}
+	return nil, errServer
+}
+
+func (r *resolver) deleteBook(p graphql.ResolveParams) (interface{}, error) {
+	if helpers.UserID(p.Context) == 0 {
+		return nil, errUnauthenticated
+	}
+	id := p.Args["id"].(int)
+	if !r.Books.Delete(p.Context, &id) {
+		return nil, errServer
+	}
+	return true, nil
+}
+
+func (r *resolver) createBlog(p graphql.ResolveParams) (interface{}, error) {
+	viewer := helpers.UserID(p.Context)
+	if viewer == 0 {
+		return nil, errUnauthenticated
+	}
+	blog := models.Blog{UserID: uint(viewer)}
+	set(p.Args, map[string]*string{"title": &blog.Title, "content": &blog.Content})
+	if _, data := r.Blogs.Create(p.Context, &blog); data != nil {
+		return data, nil
+	}
+	return nil, errServer
+}
+
+func (r *resolver) updateBlog(p graphql.ResolveParams) (interface{}, error) {
+	var blog *models.Blog
+	if _, err := owned(p, func(id int) (uint, bool) {
+		if blog = r.Blogs.Find(p.Context, &id); blog == nil {
+			return 0, false
+		}
+		return blog.UserID, true
+	}); err != nil {
+		return nil, err
+	}
+	set(p.Args, map[string]*string{"title": &blog.Title, "content": &blog.Content})
+	if _, data := r.Blogs.Update(p.Context, blog); data != nil {
+		return data, nil
+	}
+	return nil, errServer
+}
+
+func (r *resolver) deleteBlog(p graphql.ResolveParams) (interface{}, error) {
+	id, err := owned(p, func(id int) (uint, bool) {
+		blog := r.Blogs.Find(p.Context, &id)
+		if blog == nil {
+			return 0, false
+		}
+		return blog.UserID, true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !r.Blogs.Delete(p.Context, &id) {
+		return nil, errServer
+	}
+	return true, nil
+}
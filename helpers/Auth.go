@@ -0,0 +1,32 @@
+package helpers
+
+import "context"
+
+type userIDKey struct{}
+
+// WithUserID stores the id of the authenticated user for code that has no
+// access to the echo context, such as GraphQL resolvers and gRPC handlers
+func WithUserID(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserID returns the authenticated user, 0 when the request is anonymous
+func UserID(ctx context.Context) int {
+	userID, _ := ctx.Value(userIDKey{}).(int)
+	return userID
+}
+
+type loginLimitKey struct{}
+
+// WithLoginLimit stores the check charging one login attempt to the client,
+// for requests that may attempt several logins such as GraphQL documents
+func WithLoginLimit(ctx context.Context, allow func() bool) context.Context {
+	return context.WithValue(ctx, loginLimitKey{}, allow)
+}
+
+// AllowLogin charges one login attempt, it allows every attempt when the
+// request carries no limit
+func AllowLogin(ctx context.Context) bool {
+	allow, found := ctx.Value(loginLimitKey{}).(func() bool)
+	return !found || allow()
+}
@@ -0,0 +1,51 @@
+package helpers
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type consistencyKey struct{}
+
+type Consistency struct {
+	primary atomic.Bool
+	wrote   atomic.Bool
+}
+
+func WithConsistency(ctx context.Context) (context.Context, *Consistency) {
+	if found := GetConsistency(ctx); found != nil {
+		return ctx, found
+	}
+	consistency := &Consistency{}
+	return context.WithValue(ctx, consistencyKey{}, consistency), consistency
+}
+
+// WithPrimary sends the reads made with the returned context to the primary
+// without changing the consistency of ctx
+func WithPrimary(ctx context.Context) context.Context {
+	consistency := &Consistency{}
+	consistency.UsePrimary()
+	return context.WithValue(ctx, consistencyKey{}, consistency)
+}
+
+func GetConsistency(ctx context.Context) *Consistency {
+	consistency, _ := ctx.Value(consistencyKey{}).(*Consistency)
+	return consistency
+}
+
+func (c *Consistency) UsePrimary() {
+	c.primary.Store(true)
+}
+
+func (c *Consistency) MarkWrite() {
+	c.wrote.Store(true)
+	c.primary.Store(true)
+}
+
+func (c *Consistency) Primary() bool {
+	return c.primary.Load()
+}
+
+func (c *Consistency) Wrote() bool {
+	return c.wrote.Load()
+}
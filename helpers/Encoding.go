@@ -0,0 +1,395 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MediaTypes lists the media types of every format, the first one is sent
+// as the Content-Type of responses
+var MediaTypes = map[string][]string{
+	"json":    {"application/json"},
+	"xml":     {"application/xml", "text/xml"},
+	"msgpack": {"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"},
+	"csv":     {"text/csv"},
+	"ndjson":  {"application/x-ndjson"},
+	"xlsx":    {"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+}
+
+var ErrNotTabular = errors.New("[err]: only lists can be written as csv")
+
+// FormatOf returns the format of a media type, structured syntax suffixes
+// such as application/vnd.rizghz.v2+json included
+func FormatOf(mediaType string) string {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json") {
+		return "json"
+	}
+	for format, types := range MediaTypes {
+		for _, t := range types {
+			if t == mediaType {
+				return format
+			}
+		}
+	}
+	return ""
+}
+
+// NegotiateFormat picks the format preferred by an Accept header among
+// formats, JSON when the header is empty and "" when none is acceptable.
+// Ties go to the format listed first.
+func NegotiateFormat(accept string, formats ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		return "json"
+	}
+	type entry struct {
+		mediaType string
+		q         float64
+	}
+	entries := []entry{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if parsed, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			q = parsed
+		}
+		entries = append(entries, entry{mediaType, q})
+	}
+	best, bestQ := "", 0.0
+	for _, format := range formats {
+		// the most specific matching media type decides the weight
+		q, specificity := 0.0, 0
+		for _, entry := range entries {
+			if level := match(entry.mediaType, format); level > specificity {
+				q, specificity = entry.q, level
+			}
+		}
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best
+}
+
+// match tells how specifically a media type names a format: 3 exactly,
+// 2 as type/*, 1 as */* and 0 not at all
+func match(mediaType string, format string) int {
+	if mediaType == "*/*" {
+		return 1
+	}
+	if group, found := strings.CutSuffix(mediaType, "/*"); found {
+		for _, t := range MediaTypes[format] {
+			if strings.HasPrefix(t, group+"/") {
+				return 2
+			}
+		}
+		return 0
+	}
+	if FormatOf(mediaType) == format {
+		return 3
+	}
+	return 0
+}
+
+// DecodeOrdered reads a JSON document keeping the order of object keys, so
+// XML elements and CSV columns come out in the order the API writes them
+func DecodeOrdered(r io.Reader) (any, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return decodeValue(dec)
+}
+
+// OrderedMap is a JSON object with the order of its keys
+type OrderedMap struct {
+	Keys   []string
+	Values map[string]any
+}
+
+func decodeValue(dec *json.Decoder) (any, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		object := &OrderedMap{Values: map[string]any{}}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			object.Keys = append(object.Keys, key.(string))
+			object.Values[key.(string)] = value
+		}
+		_, err = dec.Token()
+		return object, err
+	case json.Delim('['):
+		list := []any{}
+		for dec.More() {
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err = dec.Token()
+		return list, err
+	}
+	return token, nil
+}
+
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.Keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		value, err := json.Marshal(m.Values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (m *OrderedMap) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeMapLen(len(m.Keys)); err != nil {
+		return err
+	}
+	for _, key := range m.Keys {
+		if err := enc.EncodeString(key); err != nil {
+			return err
+		}
+		if err := enc.Encode(msgpackValue(m.Values[key])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// msgpackValue keeps JSON numbers as integers when they have no fraction
+func msgpackValue(value any) any {
+	switch value := value.(type) {
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return n
+		}
+		f, _ := value.Float64()
+		return f
+	case []any:
+		list := make([]any, len(value))
+		for i := range value {
+			list[i] = msgpackValue(value[i])
+		}
+		return list
+	}
+	return value
+}
+
+// Encode writes a value read by DecodeOrdered in the given format. CSV only
+// takes a list of objects, with one column per key of the first object.
+func Encode(format string, w io.Writer, value any) error {
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(value)
+	case "msgpack":
+		return msgpack.NewEncoder(w).Encode(msgpackValue(value))
+	case "xml":
+		enc := xml.NewEncoder(w)
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		if err := encodeXML(enc, "response", value); err != nil {
+			return err
+		}
+		return enc.Flush()
+	case "csv":
+		return encodeCSV(w, value)
+	}
+	return fmt.Errorf("[err]: unsupported format %q", format)
+}
+
+func encodeXML(enc *xml.Encoder, name string, value any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if value == nil {
+		start.Attr = []xml.Attr{{Name: xml.Name{Local: "nil"}, Value: "true"}}
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		return enc.EncodeToken(start.End())
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case *OrderedMap:
+		for _, key := range value.Keys {
+			if err := encodeXML(enc, key, value.Values[key]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range value {
+			if err := encodeXML(enc, "item", item); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := enc.EncodeToken(xml.CharData(cell(value))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+func encodeCSV(w io.Writer, value any) error {
+	rows, found := value.([]any)
+	if !found {
+		return ErrNotTabular
+	}
+	columns := []string{}
+	if len(rows) > 0 {
+		first, found := rows[0].(*OrderedMap)
+		if !found {
+			return ErrNotTabular
+		}
+		columns = first.Keys
+	}
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		object, found := row.(*OrderedMap)
+		if !found {
+			return ErrNotTabular
+		}
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = cell(object.Values[column])
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// cell renders a scalar as text and nested values as JSON
+func cell(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// DecodeForm reads a flat XML element or a CSV header and single row into
+// form values, so request bodies bind through the form tags of the models
+func DecodeForm(format string, r io.Reader) (url.Values, error) {
+	values := url.Values{}
+	switch format {
+	case "xml":
+		dec := xml.NewDecoder(r)
+		depth, name := 0, ""
+		for {
+			token, err := dec.Token()
+			if err == io.EOF {
+				return values, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			switch token := token.(type) {
+			case xml.StartElement:
+				if depth++; depth == 2 {
+					name = token.Name.Local
+					values[name] = append(values[name], "")
+				}
+			case xml.EndElement:
+				depth--
+			case xml.CharData:
+				if depth == 2 {
+					values[name][len(values[name])-1] += string(token)
+				}
+			}
+		}
+	case "csv":
+		records, err := csv.NewReader(r).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(records) != 2 {
+			return nil, errors.New("[err]: csv bodies must hold a header and exactly one row")
+		}
+		for i, column := range records[0] {
+			if i < len(records[1]) {
+				values.Set(strings.TrimSpace(column), records[1][i])
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("[err]: unsupported format %q", format)
+}
+
+// MsgpackToJSON converts a MessagePack body to JSON for the JSON binder,
+// map keys of any type become JSON object keys
+func MsgpackToJSON(r io.Reader) ([]byte, error) {
+	dec := msgpack.NewDecoder(r)
+	dec.SetMapDecoder(func(dec *msgpack.Decoder) (any, error) {
+		return dec.DecodeUntypedMap()
+	})
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(stringKeys(value))
+}
+
+func stringKeys(value any) any {
+	switch value := value.(type) {
+	case map[any]any:
+		object := make(map[string]any, len(value))
+		for key, item := range value {
+			object[fmt.Sprint(key)] = stringKeys(item)
+		}
+		return object
+	case []any:
+		for i := range value {
+			value[i] = stringKeys(value[i])
+		}
+	}
+	return value
+}
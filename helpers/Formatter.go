@@ -8,3 +8,15 @@ func FormatResponse(message string, data any) map[string]any {
 	}
 	return res
 }
+
+// FormatResponseV2 is the envelope of the v2 api, which carries the payload
+// under data and replaces the message of failed requests by an error object
+func FormatResponseV2(status int, message string, data any) map[string]any {
+	res := make(map[string]any)
+	if status >= 400 {
+		res["error"] = map[string]any{"code": status, "message": message}
+		return res
+	}
+	res["data"] = data
+	return res
+}
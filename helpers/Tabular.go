@@ -0,0 +1,247 @@
+package helpers
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type TableWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []any) error
+	Close() error
+}
+
+type TableReader interface {
+	Next() (map[string]string, error)
+}
+
+func NewTableWriter(format string, w io.Writer) (TableWriter, error) {
+	switch format {
+	case "csv":
+		return &csvTableWriter{w: csv.NewWriter(w)}, nil
+	case "ndjson":
+		return &ndjsonTableWriter{w: json.NewEncoder(w)}, nil
+	case "xlsx":
+		return newXlsxTableWriter(w)
+	}
+	return nil, fmt.Errorf("[err]: unsupported format %q", format)
+}
+
+func NewTableReader(format string, r io.Reader) (TableReader, error) {
+	switch format {
+	case "csv":
+		reader := csv.NewReader(r)
+		reader.TrimLeadingSpace = true
+		reader.FieldsPerRecord = -1
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("[err]: invalid csv header: %v", err)
+		}
+		for i := range header {
+			header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+		}
+		return &csvTableReader{r: reader, header: header}, nil
+	case "ndjson":
+		return &ndjsonTableReader{r: bufio.NewScanner(r)}, nil
+	}
+	return nil, fmt.Errorf("[err]: unsupported format %q", format)
+}
+
+func TableContentType(format string) string {
+	switch format {
+	case "csv":
+		return "text/csv; charset=utf-8"
+	case "ndjson":
+		return "application/x-ndjson"
+	case "xlsx":
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "application/octet-stream"
+}
+
+type csvTableWriter struct {
+	w *csv.Writer
+}
+
+func (t *csvTableWriter) WriteHeader(columns []string) error {
+	return t.w.Write(columns)
+}
+
+func (t *csvTableWriter) WriteRow(values []any) error {
+	row := make([]string, len(values))
+	for i, val := range values {
+		row[i] = fmt.Sprint(val)
+	}
+	return t.w.Write(row)
+}
+
+func (t *csvTableWriter) Close() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+type ndjsonTableWriter struct {
+	w       *json.Encoder
+	columns []string
+}
+
+func (t *ndjsonTableWriter) WriteHeader(columns []string) error {
+	t.columns = columns
+	return nil
+}
+
+func (t *ndjsonTableWriter) WriteRow(values []any) error {
+	row := make(map[string]any, len(values))
+	for i, val := range values {
+		if i < len(t.columns) {
+			row[t.columns[i]] = val
+		}
+	}
+	return t.w.Encode(row)
+}
+
+func (t *ndjsonTableWriter) Close() error {
+	return nil
+}
+
+type xlsxTableWriter struct {
+	zip   *zip.Writer
+	sheet io.Writer
+	rows  int
+}
+
+func newXlsxTableWriter(w io.Writer) (*xlsxTableWriter, error) {
+	archive := zip.NewWriter(w)
+	parts := [][2]string{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>` +
+			`</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+	}
+	for _, part := range parts {
+		f, err := archive.Create(part[0])
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part[1]); err != nil {
+			return nil, err
+		}
+	}
+	// the worksheet must be the last entry so rows can be streamed into it
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.WriteString(sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxTableWriter{zip: archive, sheet: sheet}, nil
+}
+
+func (t *xlsxTableWriter) WriteHeader(columns []string) error {
+	values := make([]any, len(columns))
+	for i, col := range columns {
+		values[i] = col
+	}
+	return t.WriteRow(values)
+}
+
+func (t *xlsxTableWriter) WriteRow(values []any) error {
+	t.rows++
+	var b strings.Builder
+	fmt.Fprintf(&b, `<row r="%d">`, t.rows)
+	for _, val := range values {
+		switch v := val.(type) {
+		case int, int64, uint, uint64, float64:
+			fmt.Fprintf(&b, `<c><v>%v</v></c>`, v)
+		default:
+			b.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(&b, []byte(fmt.Sprint(v))); err != nil {
+				return err
+			}
+			b.WriteString(`</t></is></c>`)
+		}
+	}
+	b.WriteString(`</row>`)
+	_, err := io.WriteString(t.sheet, b.String())
+	return err
+}
+
+func (t *xlsxTableWriter) Close() error {
+	if _, err := io.WriteString(t.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return t.zip.Close()
+}
+
+type csvTableReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+func (t *csvTableReader) Next() (map[string]string, error) {
+	record, err := t.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	row := make(map[string]string, len(t.header))
+	for i, col := range t.header {
+		if i < len(record) {
+			row[col] = strings.TrimSpace(record[i])
+		}
+	}
+	return row, nil
+}
+
+type ndjsonTableReader struct {
+	r *bufio.Scanner
+}
+
+func (t *ndjsonTableReader) Next() (map[string]string, error) {
+	for t.r.Scan() {
+		line := strings.TrimSpace(t.r.Text())
+		if line == "" {
+			continue
+		}
+		raw, decoder := map[string]any{}, json.NewDecoder(strings.NewReader(line))
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, errors.New("[err]: invalid json line")
+		}
+		row := make(map[string]string, len(raw))
+		for key, val := range raw {
+			if val != nil {
+				row[key] = strings.TrimSpace(fmt.Sprint(val))
+			}
+		}
+		return row, nil
+	}
+	if err := t.r.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
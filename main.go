@@ -1,60 +1,197 @@
 package main
 
 import (
+	"context"
 	"log"
-	"net/http"
+	"os"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/rizghz/api/cache"
 	"github.com/rizghz/api/configs"
 	"github.com/rizghz/api/controllers"
+	"github.com/rizghz/api/graph"
+	"github.com/rizghz/api/helpers"
+	"github.com/rizghz/api/metrics"
 	"github.com/rizghz/api/models"
 	"github.com/rizghz/api/routes"
+	mw "github.com/rizghz/api/routes/middleware"
+	"github.com/rizghz/api/rpc"
+	"github.com/rizghz/api/search"
+	"github.com/rizghz/api/tracing"
+	"google.golang.org/grpc"
 )
 
 func main() {
-	env, err := configs.NewDatabaseEnv()
+	args := os.Args[1:]
+	if len(args) > 1 && args[0] == "migrate" && args[1] == "create" {
+		createMigration(args[2:])
+		return
+	}
+
+	conf, err := configs.Load()
+	if err != nil {
+		log.Fatalf("%v", err.Error())
+	}
+
+	if err := helpers.ConfigureLogger(&conf.Log); err != nil {
+		log.Fatalf("%v", err.Error())
+	}
+
+	flush, err := tracing.Init(context.Background(), &conf.Tracing)
+	if err != nil {
+		log.Fatalf("%v", err.Error())
+	}
+
+	db, err := models.Init(&conf.Database)
 	if err != nil {
 		log.Fatalf("%v", err.Error())
 	}
 
-	conf := configs.NewDatabaseConfig(env)
-	db, err := models.Init(conf)
+	if len(args) > 0 && args[0] == "migrate" {
+		migrate(db, args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "seed" {
+		seed(db, conf, args[1:])
+		return
+	}
+	if len(args) > 0 && args[0] == "reindex" {
+		reindex(db, conf)
+		return
+	}
+
+	pending, err := models.PendingMigrations(db)
 	if err != nil {
 		log.Fatalf("%v", err.Error())
 	}
+	if pending > 0 && !conf.Database.AutoMigrate {
+		log.Fatalf("[err]: database schema is %d migration(s) behind, run `migrate up` or set DB_AUTO_MIGRATE=true", pending)
+	}
+	if pending > 0 {
+		if _, err := models.MigrateUp(db); err != nil {
+			log.Fatalf("%v", err.Error())
+		}
+	}
 
-	err = models.Migrate(db)
+	sqlDB, err := db.DB()
 	if err != nil {
 		log.Fatalf("%v", err.Error())
 	}
+	if err := metrics.RegisterDB(sqlDB, "primary"); err != nil {
+		log.Fatalf("%v", err.Error())
+	}
+
+	var (
+		index models.ISearchIndex
+		hooks []models.Hook
+		// log.Fatalf skips deferred calls, so the fatal paths below close the
+		// index themselves to release its lock
+		closeIndex = func() {}
+	)
+	if conf.Search.IndexEnabled {
+		opened, err := search.Open(&conf.Search)
+		if err != nil {
+			log.Fatalf("%v", err.Error())
+		}
+		index, hooks, closeIndex = opened, []models.Hook{opened}, func() { opened.Close() }
+	}
+	defer closeIndex()
 
-	mUser := models.NewUserModel(db)
+	mUser := models.NewUserModel(db, &conf.Jwt)
 	cUser := controllers.NewUserController(mUser)
 
-	mBook := models.NewBookModel(db)
+	mBook := models.NewBookModel(db, hooks...)
+	mBlog := models.NewBlogModel(db, hooks...)
+	// users are left out as logins write their token outside of Update
+	if conf.Cache.Enabled {
+		reads := cache.New(cache.NewMemoryStore(conf.Cache.Size), conf.Cache.TTL)
+		mBook, mBlog = cache.NewBooks(mBook, reads), cache.NewBlogs(mBlog, reads)
+	}
 	cBook := controllers.NewBookController(mBook)
-
-	mBlog := models.NewBlogModel(db)
 	cBlog := controllers.NewBlogController(mBlog)
 
+	cSearch := controllers.NewSearchController(mBook, mBlog, index)
+
+	executor, err := graph.NewExecutor(graph.Models{Users: mUser, Books: mBook, Blogs: mBlog}, graph.Limits{
+		MaxDepth:      conf.GraphQL.MaxDepth,
+		MaxComplexity: conf.GraphQL.MaxComplexity,
+		ListCost:      conf.GraphQL.ListCost,
+	})
+	if err != nil {
+		closeIndex()
+		log.Fatalf("%v", err.Error())
+	}
+	cGraphQL := controllers.NewGraphQLController(executor)
+
+	limiter := mw.NewRateLimiter(&conf.RateLimit, mw.NewMemoryStore())
+	var server *grpc.Server
+	if conf.GRPC.Enabled {
+		server = rpc.NewServer(&conf.GRPC, &conf.Jwt, limiter, mUser, mBook, mBlog)
+	}
+
+	checks := []controllers.HealthCheck{
+		{Name: "database", Check: func(ctx context.Context) error { return models.Ping(ctx, db) }},
+		{Name: "migrations", Check: func(ctx context.Context) error { return models.CheckMigrations(ctx, db) }},
+	}
+	if len(conf.Database.ReplicaDSNs) > 0 {
+		checks = append(checks, controllers.HealthCheck{
+			Name: "database_replica", Check: func(ctx context.Context) error { return models.PingReplica(ctx, db) },
+		})
+	}
+	cHealth := controllers.NewHealthController(conf.Server.HealthTimeout, checks...)
+
 	e := echo.New()
+	e.Binder = &mw.Binder{}
+	proxies, _ := conf.Security.TrustedNets()
+	e.IPExtractor = mw.IPExtractor(proxies)
 
+	e.Pre(mw.Versioning(&conf.API, "/users", "/books", "/blogs", "/search"))
 	e.Use(middleware.RemoveTrailingSlash())
-	e.Use(middleware.CORS())
-	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
-		Format: "method=${method}, uri=${uri}, status=${status} time=${time_rfc3339}\n",
+	e.Use(mw.RequestID())
+	e.Use(mw.Tracing())
+	e.Use(mw.Logger())
+	e.Use(mw.Metrics())
+	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins:     conf.Security.AllowOrigins,
+		AllowMethods:     conf.Security.AllowMethods,
+		AllowHeaders:     conf.Security.AllowHeaders,
+		ExposeHeaders:    conf.Security.ExposeHeaders,
+		AllowCredentials: conf.Security.AllowCredentials,
+		MaxAge:           int(conf.Security.MaxAge.Seconds()),
+	}))
+	e.Use(middleware.SecureWithConfig(middleware.SecureConfig{
+		ContentTypeNosniff:    "nosniff",
+		XFrameOptions:         conf.Security.FrameOptions,
+		HSTSMaxAge:            int(conf.Security.HSTSMaxAge.Seconds()),
+		HSTSExcludeSubdomains: !conf.Security.HSTSIncludeSubdomains,
+		ContentSecurityPolicy: conf.Security.ContentSecurityPolicy,
+		ReferrerPolicy:        conf.Security.ReferrerPolicy,
 	}))
+	e.Use(mw.Compress(conf.Server.Compression, conf.Server.CompressMinSize))
+	e.Use(mw.Timeout(conf.Server.RequestTimeout, conf.Server.ExportTimeout))
+	e.Use(mw.ReadYourWrites(conf.Database.ReadYourWrites))
 
-	routes.UserRoute(e, cUser)
-	routes.BookRoute(e, cBook)
-	routes.BlogRoute(e, cBlog)
+	idempotent := mw.Idempotency(&conf.Idempotency, mw.NewIdempotencyMemoryStore(), limiter)
+	limits := &conf.Security.BodyLimits
+	// every version shares the controllers until an endpoint changes
+	for _, version := range conf.API.Versions {
+		api := routes.VersionRoute(e, version, &conf.API, &conf.Jwt)
+		routes.UserRoute(api, cUser, &conf.Jwt, limiter, limits, idempotent)
+		routes.BookRoute(api, cBook, limiter, limits, idempotent)
+		routes.BlogRoute(api, cBlog, limiter, limits, idempotent)
+		routes.SearchRoute(api, cSearch, limiter)
+	}
 
-	e.GET("/coba", func(ctx echo.Context) error {
-		return ctx.JSON(http.StatusOK, map[string]any{
-			"message": "success",
-		})
-	})
+	if conf.GraphQL.Enabled {
+		routes.GraphQLRoute(e, cGraphQL, &conf.Jwt, limiter, limits)
+	}
+	routes.HealthRoute(e, cHealth)
+	routes.MetricsRoute(e)
+	routes.DocsRoute(e, &conf.RateLimit, &conf.API)
 
-	e.Logger.Fatal(e.Start(":8008").Error())
+	if err := serve(e, &conf.Server, server, conf.GRPC.Address, db, cHealth.Shutdown, flush); err != nil {
+		closeIndex()
+		log.Fatalf("%v", err.Error())
+	}
 }
@@ -1,8 +1,11 @@
 package models
 
 import (
-	"github.com/sirupsen/logrus"
+	"context"
+
+	"github.com/rizghz/api/helpers"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Blog struct {
@@ -10,85 +13,173 @@ type Blog struct {
 	Title   string `json:"title" form:"title"`
 	Content string `json:"content" form:"content"`
 	UserID  uint   `json:"user_id" form:"user_id"`
+	Author  *User  `json:"author,omitempty" form:"-" gorm:"foreignKey:UserID"`
+}
+
+var (
+	BlogFields = Fields{
+		"id": "id", "createdat": "created_at", "updatedat": "updated_at",
+		"title": "title", "content": "content", "userid": "user_id",
+	}
+	BlogRelations = Relations{"author": {Name: "Author", Keys: []string{"user_id"}, Fields: UserFields}}
+)
+
+var blogSearch = searchable{
+	table:   "blogs",
+	columns: []string{"title", "content"},
+	weights: []float64{3, 1},
 }
 
 type BlogModel struct {
-	db *gorm.DB
+	db    *gorm.DB
+	hooks []Hook
 }
 
 type IBlogModel interface {
-	Get() []Blog
-	Find(key *int) *Blog
-	Create(blog *Blog) (bool, *Blog)
-	Update(blog *Blog) (bool, *Blog)
-	Delete(key *int) bool
+	Get(ctx context.Context) []Blog
+	Find(ctx context.Context, key *int) *Blog
+	Create(ctx context.Context, blog *Blog) (bool, *Blog)
+	Update(ctx context.Context, blog *Blog) (bool, *Blog)
+	Delete(ctx context.Context, key *int) bool
+	Search(ctx context.Context, query SearchQuery) *SearchResult
+	GetByUsers(ctx context.Context, userIDs []uint) map[uint][]Blog
+	Each(ctx context.Context, fn func(blog *Blog) error) error
 }
 
-func NewBlogModel(db *gorm.DB) IBlogModel {
+func NewBlogModel(db *gorm.DB, hooks ...Hook) IBlogModel {
 	return &BlogModel{
-		db: db,
+		db:    db,
+		hooks: hooks,
 	}
 }
 
-func (m *BlogModel) Get() []Blog {
+func (m *BlogModel) Get(ctx context.Context) []Blog {
+	ctx, span := observe(ctx, "BlogModel.Get")
+	defer span.End()
 	blogs := []Blog{}
-	if err := m.db.Find(&blogs).Error; err != nil {
-		logrus.Error(err.Error())
+	if err := reader(ctx, m.db).Scopes(selected(ctx, BlogFields, BlogRelations)).Find(&blogs).Error; err != nil {
+		helpers.Log(ctx).Error(err.Error())
 		return nil
 	}
 	return blogs
 }
 
-func (m *BlogModel) Find(key *int) *Blog {
+func (m *BlogModel) Each(ctx context.Context, fn func(blog *Blog) error) error {
+	ctx, span := observe(ctx, "BlogModel.Each")
+	defer span.End()
+	rows, err := reader(ctx, m.db).Model(&Blog{}).Order("id").Rows()
+	if err != nil {
+		helpers.Log(ctx).Error(err.Error())
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		blog := Blog{}
+		if err := reader(ctx, m.db).ScanRows(rows, &blog); err != nil {
+			helpers.Log(ctx).Error(err.Error())
+			return err
+		}
+		if err := fn(&blog); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetByUsers loads the blogs of several users in a single query
+func (m *BlogModel) GetByUsers(ctx context.Context, userIDs []uint) map[uint][]Blog {
+	ctx, span := observe(ctx, "BlogModel.GetByUsers")
+	defer span.End()
+	blogs := []Blog{}
+	if err := reader(ctx, m.db).Where("user_id IN ?", userIDs).Order("id").Find(&blogs).Error; err != nil {
+		helpers.Log(ctx).Error(err.Error())
+		return nil
+	}
+	grouped := make(map[uint][]Blog, len(userIDs))
+	for _, blog := range blogs {
+		grouped[blog.UserID] = append(grouped[blog.UserID], blog)
+	}
+	return grouped
+}
+
+func (m *BlogModel) Find(ctx context.Context, key *int) *Blog {
+	ctx, span := observe(ctx, "BlogModel.Find")
+	defer span.End()
 	blog := Blog{}
-	if err := m.db.First(&blog, *key).Error; err != nil {
-		logrus.Error(err.Error())
+	if err := reader(ctx, m.db).Scopes(selected(ctx, BlogFields, BlogRelations)).First(&blog, *key).Error; err != nil {
+		helpers.Log(ctx).Error(err.Error())
 		return nil
 	}
 	return &blog
 }
 
-func (m *BlogModel) Create(blog *Blog) (bool, *Blog) {
-	err := m.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Create(&blog).Error; err != nil {
-			logrus.Error(err.Error())
+func (m *BlogModel) Search(ctx context.Context, query SearchQuery) *SearchResult {
+	ctx, span := observe(ctx, "BlogModel.Search")
+	defer span.End()
+	return searchRecords(ctx, m.db, blogSearch, query, func(record Blog) (uint, []string) {
+		return record.ID, []string{record.Content, record.Title}
+	})
+}
+
+func (m *BlogModel) Create(ctx context.Context, blog *Blog) (bool, *Blog) {
+	ctx, span := observe(ctx, "BlogModel.Create")
+	defer span.End()
+	err := writer(ctx, m.db).Transaction(func(tx *gorm.DB) error {
+		// the author is read only, a nested one must not create a user
+		if err := tx.Omit(clause.Associations).Create(&blog).Error; err != nil {
+			helpers.Log(ctx).Error(err.Error())
 			return err
 		}
 		return nil
 	})
 	if err != nil {
-		logrus.Error(err.Error())
+		helpers.Log(ctx).Error(err.Error())
 		return false, nil
 	}
+	for _, hook := range m.hooks {
+		hook.AfterSave(ctx, blog)
+	}
 	return true, blog
 }
 
-func (m *BlogModel) Update(blog *Blog) (bool, *Blog) {
-	err := m.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Save(&blog).Error; err != nil {
-			logrus.Error(err.Error())
+func (m *BlogModel) Update(ctx context.Context, blog *Blog) (bool, *Blog) {
+	ctx, span := observe(ctx, "BlogModel.Update")
+	defer span.End()
+	err := writer(ctx, m.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(&blog).Error; err != nil {
+			helpers.Log(ctx).Error(err.Error())
 			return err
 		}
 		return nil
 	})
 	if err != nil {
-		logrus.Error(err.Error())
+		helpers.Log(ctx).Error(err.Error())
 		return false, nil
 	}
+	for _, hook := range m.hooks {
+		hook.AfterSave(ctx, blog)
+	}
 	return true, blog
 }
 
-func (m *BlogModel) Delete(key *int) bool {
-	err := m.db.Transaction(func(tx *gorm.DB) error {
+func (m *BlogModel) Delete(ctx context.Context, key *int) bool {
+	ctx, span := observe(ctx, "BlogModel.Delete")
+	defer span.End()
+	err := writer(ctx, m.db).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Delete(&Blog{}, *key).Error; err != nil {
-			logrus.Error(err.Error())
+			helpers.Log(ctx).Error(err.Error())
 			return err
 		}
 		return nil
 	})
 	if err != nil {
-		logrus.Error(err.Error())
+		helpers.Log(ctx).Error(err.Error())
 		return false
 	}
+	deleted := &Blog{}
+	deleted.ID = uint(*key)
+	for _, hook := range m.hooks {
+		hook.AfterDelete(ctx, deleted)
+	}
 	return true
 }
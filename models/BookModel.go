@@ -1,92 +1,180 @@
 package models
 
 import (
-	"github.com/sirupsen/logrus"
+	"context"
+
+	"github.com/rizghz/api/helpers"
 	"gorm.io/gorm"
 )
 
 type Book struct {
 	gorm.Model
+	ISBN      string `json:"isbn" form:"isbn" gorm:"index"`
 	Title     string `json:"title" form:"title"`
 	Author    string `json:"author" form:"author"`
 	Publisher string `json:"publisher" form:"publisher"`
 }
 
+var BookFields = Fields{
+	"id": "id", "createdat": "created_at", "updatedat": "updated_at",
+	"isbn": "isbn", "title": "title", "author": "author", "publisher": "publisher",
+}
+
+var bookSearch = searchable{
+	table:   "books",
+	columns: []string{"title", "author", "publisher"},
+	weights: []float64{3, 2, 1},
+}
+
 type BookModel struct {
-	db *gorm.DB
+	db    *gorm.DB
+	hooks []Hook
 }
 
 type IBookModel interface {
-	Get() []Book
-	Find(key *int) *Book
-	Create(user *Book) (bool, *Book)
-	Update(user *Book) (bool, *Book)
-	Delete(key *int) bool
+	Get(ctx context.Context) []Book
+	Find(ctx context.Context, key *int) *Book
+	Create(ctx context.Context, user *Book) (bool, *Book)
+	Update(ctx context.Context, user *Book) (bool, *Book)
+	Delete(ctx context.Context, key *int) bool
+	Search(ctx context.Context, query SearchQuery) *SearchResult
+	Each(ctx context.Context, fn func(book *Book) error) error
+	Duplicate(ctx context.Context, book *Book) *Book
 }
 
-func NewBookModel(db *gorm.DB) IBookModel {
+func NewBookModel(db *gorm.DB, hooks ...Hook) IBookModel {
 	return &BookModel{
-		db: db,
+		db:    db,
+		hooks: hooks,
 	}
 }
 
-func (m *BookModel) Get() []Book {
+func (m *BookModel) Get(ctx context.Context) []Book {
+	ctx, span := observe(ctx, "BookModel.Get")
+	defer span.End()
 	books := []Book{}
-	if err := m.db.Find(&books).Error; err != nil {
-		logrus.Error(err.Error())
+	if err := reader(ctx, m.db).Scopes(selected(ctx, BookFields, nil)).Find(&books).Error; err != nil {
+		helpers.Log(ctx).Error(err.Error())
 		return nil
 	}
 	return books
 }
 
-func (m *BookModel) Find(key *int) *Book {
+func (m *BookModel) Find(ctx context.Context, key *int) *Book {
+	ctx, span := observe(ctx, "BookModel.Find")
+	defer span.End()
 	book := Book{}
-	if err := m.db.First(&book, *key).Error; err != nil {
-		logrus.Error(err.Error())
+	if err := reader(ctx, m.db).Scopes(selected(ctx, BookFields, nil)).First(&book, *key).Error; err != nil {
+		helpers.Log(ctx).Error(err.Error())
 		return nil
 	}
 	return &book
 }
 
-func (m *BookModel) Create(book *Book) (bool, *Book) {
-	err := m.db.Transaction(func(tx *gorm.DB) error {
+func (m *BookModel) Search(ctx context.Context, query SearchQuery) *SearchResult {
+	ctx, span := observe(ctx, "BookModel.Search")
+	defer span.End()
+	return searchRecords(ctx, m.db, bookSearch, query, func(record Book) (uint, []string) {
+		return record.ID, []string{record.Title, record.Author, record.Publisher}
+	})
+}
+
+func (m *BookModel) Create(ctx context.Context, book *Book) (bool, *Book) {
+	ctx, span := observe(ctx, "BookModel.Create")
+	defer span.End()
+	err := writer(ctx, m.db).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(book).Error; err != nil {
-			logrus.Error(err.Error())
+			helpers.Log(ctx).Error(err.Error())
 			return err
 		}
 		return nil
 	})
 	if err != nil {
-		logrus.Error(err.Error())
+		helpers.Log(ctx).Error(err.Error())
 		return false, nil
 	}
+	for _, hook := range m.hooks {
+		hook.AfterSave(ctx, book)
+	}
 	return true, book
 }
 
-func (m *BookModel) Update(book *Book) (bool, *Book) {
-	err := m.db.Transaction(func(tx *gorm.DB) error {
+func (m *BookModel) Update(ctx context.Context, book *Book) (bool, *Book) {
+	ctx, span := observe(ctx, "BookModel.Update")
+	defer span.End()
+	err := writer(ctx, m.db).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Save(book).Error; err != nil {
 			return err
 		}
 		return nil
 	})
 	if err != nil {
-		logrus.Error(err.Error())
+		helpers.Log(ctx).Error(err.Error())
 		return false, nil
 	}
+	for _, hook := range m.hooks {
+		hook.AfterSave(ctx, book)
+	}
 	return true, book
 }
 
-func (m *BookModel) Delete(key *int) bool {
-	err := m.db.Transaction(func(tx *gorm.DB) error {
+func (m *BookModel) Delete(ctx context.Context, key *int) bool {
+	ctx, span := observe(ctx, "BookModel.Delete")
+	defer span.End()
+	err := writer(ctx, m.db).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Delete(&Book{}, *key).Error; err != nil {
 			return err
 		}
 		return nil
 	})
 	if err != nil {
-		logrus.Error(err.Error())
+		helpers.Log(ctx).Error(err.Error())
 		return false
 	}
+	deleted := &Book{}
+	deleted.ID = uint(*key)
+	for _, hook := range m.hooks {
+		hook.AfterDelete(ctx, deleted)
+	}
 	return true
 }
+
+func (m *BookModel) Each(ctx context.Context, fn func(book *Book) error) error {
+	ctx, span := observe(ctx, "BookModel.Each")
+	defer span.End()
+	rows, err := reader(ctx, m.db).Model(&Book{}).Order("id").Rows()
+	if err != nil {
+		helpers.Log(ctx).Error(err.Error())
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		book := Book{}
+		if err := reader(ctx, m.db).ScanRows(rows, &book); err != nil {
+			helpers.Log(ctx).Error(err.Error())
+			return err
+		}
+		if err := fn(&book); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (m *BookModel) Duplicate(ctx context.Context, book *Book) *Book {
+	ctx, span := observe(ctx, "BookModel.Duplicate")
+	defer span.End()
+	query := reader(ctx, m.db).Where("LOWER(title) = LOWER(?) AND LOWER(author) = LOWER(?)", book.Title, book.Author)
+	if book.ISBN != "" {
+		query = reader(ctx, m.db).Where("isbn = ?", book.ISBN).Or(query)
+	}
+	found := Book{}
+	if err := query.Limit(1).Find(&found).Error; err != nil {
+		helpers.Log(ctx).Error(err.Error())
+		return nil
+	}
+	if found.ID == 0 {
+		return nil
+	}
+	return &found
+}
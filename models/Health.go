@@ -0,0 +1,71 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+func Ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// PingReplica pings every replica pool, as the resolver would route a
+// probe query to a single one or even to the primary
+func PingReplica(ctx context.Context, db *gorm.DB) error {
+	pools, err := replicas(db)
+	if err != nil {
+		return err
+	}
+	for i, pool := range pools {
+		if err := pool.PingContext(ctx); err != nil {
+			return fmt.Errorf("replica %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// replicas returns the pools the resolver opened for the replicas
+func replicas(db *gorm.DB) ([]*sql.DB, error) {
+	resolver, found := db.Config.Plugins[(&dbresolver.DBResolver{}).Name()].(*dbresolver.DBResolver)
+	if !found {
+		return nil, errors.New("[err]: no replica is configured")
+	}
+	primary, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	pools := []*sql.DB{}
+	err = resolver.Call(func(pool gorm.ConnPool) error {
+		if sqlDB, found := pool.(*sql.DB); found && sqlDB != primary {
+			pools = append(pools, sqlDB)
+		}
+		return nil
+	})
+	return pools, err
+}
+
+func CheckMigrations(ctx context.Context, db *gorm.DB) error {
+	states, err := migrationStates(db.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, state := range states {
+		if !state.Applied {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d pending migration(s)", pending)
+	}
+	return nil
+}
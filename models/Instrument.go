@@ -0,0 +1,111 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rizghz/api/metrics"
+	"github.com/rizghz/api/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+type methodKey struct{}
+
+// observe labels every query issued with ctx by the calling model method
+// and opens a span for the call, which the caller must end
+func observe(ctx context.Context, method string) (context.Context, trace.Span) {
+	ctx, span := tracing.Tracer().Start(context.WithValue(ctx, methodKey{}, method), method)
+	return ctx, span
+}
+
+func methodOf(ctx context.Context) string {
+	if method, found := ctx.Value(methodKey{}).(string); found {
+		return method
+	}
+	return "unknown"
+}
+
+func registerMetrics(db *gorm.DB) error {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet("metrics:start", time.Now())
+	}
+	after := func(tx *gorm.DB) {
+		start, found := tx.InstanceGet("metrics:start")
+		if !found {
+			return
+		}
+		method := methodOf(tx.Statement.Context)
+		metrics.DbQueryDuration.WithLabelValues(method).Observe(time.Since(start.(time.Time)).Seconds())
+		if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			metrics.DbQueryErrors.WithLabelValues(method).Inc()
+		}
+	}
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("metrics:before_create", before),
+		callbacks.Create().After("gorm:create").Register("metrics:after_create", after),
+		callbacks.Query().Before("gorm:query").Register("metrics:before_query", before),
+		callbacks.Query().After("gorm:query").Register("metrics:after_query", after),
+		callbacks.Update().Before("gorm:update").Register("metrics:before_update", before),
+		callbacks.Update().After("gorm:update").Register("metrics:after_update", after),
+		callbacks.Delete().Before("gorm:delete").Register("metrics:before_delete", before),
+		callbacks.Delete().After("gorm:delete").Register("metrics:after_delete", after),
+		callbacks.Row().Before("gorm:row").Register("metrics:before_row", before),
+		callbacks.Row().After("gorm:row").Register("metrics:after_row", after),
+		callbacks.Raw().Before("gorm:raw").Register("metrics:before_raw", before),
+		callbacks.Raw().After("gorm:raw").Register("metrics:after_raw", after),
+	)
+}
+
+func registerTracing(db *gorm.DB) error {
+	before := func(operation string) func(tx *gorm.DB) {
+		return func(tx *gorm.DB) {
+			ctx, span := tracing.Tracer().Start(tx.Statement.Context, "sql."+operation,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemKey.String(tx.Dialector.Name()),
+					semconv.DBOperation(operation),
+				),
+			)
+			tx.Statement.Context = ctx
+			tx.InstanceSet("tracing:span", span)
+		}
+	}
+	after := func(tx *gorm.DB) {
+		value, found := tx.InstanceGet("tracing:span")
+		if !found {
+			return
+		}
+		span := value.(trace.Span)
+		defer span.End()
+		span.SetAttributes(
+			semconv.DBStatementKey.String(tx.Statement.SQL.String()),
+			semconv.DBSQLTableKey.String(tx.Statement.Table),
+			attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+		)
+		if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			span.RecordError(tx.Error)
+			span.SetStatus(codes.Error, tx.Error.Error())
+		}
+	}
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("tracing:before_create", before("insert")),
+		callbacks.Create().After("gorm:create").Register("tracing:after_create", after),
+		callbacks.Query().Before("gorm:query").Register("tracing:before_query", before("select")),
+		callbacks.Query().After("gorm:query").Register("tracing:after_query", after),
+		callbacks.Update().Before("gorm:update").Register("tracing:before_update", before("update")),
+		callbacks.Update().After("gorm:update").Register("tracing:after_update", after),
+		callbacks.Delete().Before("gorm:delete").Register("tracing:before_delete", before("delete")),
+		callbacks.Delete().After("gorm:delete").Register("tracing:after_delete", after),
+		callbacks.Row().Before("gorm:row").Register("tracing:before_row", before("row")),
+		callbacks.Row().After("gorm:row").Register("tracing:after_row", after),
+		callbacks.Raw().Before("gorm:raw").Register("tracing:before_raw", before("raw")),
+		callbacks.Raw().After("gorm:raw").Register("tracing:after_raw", after),
+	)
+}
@@ -0,0 +1,240 @@
+package models
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationPattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationState struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+func Migrations(driver string) ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, path.Join("migrations", driver))
+	if err != nil {
+		return nil, fmt.Errorf("[err]: no migrations for driver %s", driver)
+	}
+	found := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationPattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		content, err := migrationFiles.ReadFile(path.Join("migrations", driver, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if found[version] == nil {
+			found[version] = &Migration{Version: version, Name: match[2]}
+		}
+		if match[3] == "up" {
+			found[version].Up = string(content)
+		} else {
+			found[version].Down = string(content)
+		}
+	}
+	migrations := make([]Migration, 0, len(found))
+	for _, migration := range found {
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func MigrationStatus(db *gorm.DB) ([]MigrationState, error) {
+	if err := db.Clauses(dbresolver.Write).AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+	return migrationStates(db)
+}
+
+// migrationStates reads schema_migrations without creating or altering it,
+// so health checks can call it on every probe
+func migrationStates(db *gorm.DB) ([]MigrationState, error) {
+	migrations, err := Migrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	// always read the migration state from the primary database
+	primary := db.Clauses(dbresolver.Write)
+	applied := []SchemaMigration{}
+	if err := primary.Order("version").Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	versions := make(map[int64]time.Time, len(applied))
+	for _, row := range applied {
+		versions[row.Version] = row.AppliedAt
+	}
+	states := make([]MigrationState, len(migrations))
+	for i, migration := range migrations {
+		states[i] = MigrationState{Migration: migration}
+		if at, found := versions[migration.Version]; found {
+			states[i].Applied, states[i].AppliedAt = true, &at
+		}
+	}
+	return states, nil
+}
+
+func PendingMigrations(db *gorm.DB) (int, error) {
+	states, err := MigrationStatus(db)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, state := range states {
+		if !state.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func MigrateUp(db *gorm.DB) ([]Migration, error) {
+	states, err := MigrationStatus(db)
+	if err != nil {
+		return nil, err
+	}
+	applied := []Migration{}
+	for _, state := range states {
+		if state.Applied {
+			continue
+		}
+		err := transactional(db, func(tx *gorm.DB) error {
+			if err := execStatements(tx, state.Up); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   state.Version,
+				Name:      state.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("[err]: migration %d_%s failed: %v", state.Version, state.Name, err)
+		}
+		applied = append(applied, state.Migration)
+	}
+	return applied, nil
+}
+
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	states, err := MigrationStatus(db)
+	if err != nil {
+		return nil, err
+	}
+	reverted := []Migration{}
+	for i := len(states) - 1; i >= 0 && len(reverted) < steps; i-- {
+		state := states[i]
+		if !state.Applied {
+			continue
+		}
+		err := transactional(db, func(tx *gorm.DB) error {
+			if err := execStatements(tx, state.Down); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, state.Version).Error
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("[err]: migration %d_%s failed: %v", state.Version, state.Name, err)
+		}
+		reverted = append(reverted, state.Migration)
+	}
+	return reverted, nil
+}
+
+func CreateMigration(dir string, name string) ([]string, error) {
+	if !regexp.MustCompile(`^\w+$`).MatchString(name) {
+		return nil, errors.New("[err]: migration name must only contain letters, digits and underscores")
+	}
+	version := time.Now().UTC().Format("20060102150405")
+	drivers, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, driver := range drivers {
+		for _, direction := range []string{"up", "down"} {
+			file := filepath.Join(dir, driver.Name(), fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+			if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+				return files, err
+			}
+			if err := os.WriteFile(file, []byte("-- "+direction+" migration for "+name+"\n"), 0o644); err != nil {
+				return files, err
+			}
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// transactional runs a migration in a transaction where the driver can roll
+// DDL back. MySQL commits every DDL statement on its own, so a migration
+// failing there leaves its earlier statements applied and unrecorded.
+func transactional(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if db.Dialector.Name() != "mysql" {
+		return db.Transaction(fn)
+	}
+	if err := fn(db); err != nil {
+		return fmt.Errorf("%v (earlier statements stay applied, mysql cannot roll DDL back)", err)
+	}
+	return nil
+}
+
+func execStatements(tx *gorm.DB, script string) error {
+	for _, statement := range strings.Split(script, ";\n") {
+		lines := []string{}
+		for _, line := range strings.Split(statement, "\n") {
+			if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				lines = append(lines, line)
+			}
+		}
+		statement = strings.TrimSuffix(strings.TrimSpace(strings.Join(lines, "\n")), ";")
+		if statement == "" {
+			continue
+		}
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,27 +1,148 @@
 package models
 
 import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/glebarez/sqlite"
 	"github.com/rizghz/api/configs"
+	"github.com/sirupsen/logrus"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
+func Dialector(config *configs.DatabaseConfig) gorm.Dialector {
+	return dialector(config.Driver, config.ConnectStr())
+}
+
+func dialector(driver string, dsn string) gorm.Dialector {
+	switch driver {
+	case "postgres":
+		return postgres.Open(dsn)
+	case "sqlite":
+		return sqlite.Open(dsn)
+	}
+	return mysql.Open(dsn)
+}
+
 func Init(config *configs.DatabaseConfig) (*gorm.DB, error) {
-	db, err := gorm.Open(mysql.Open(config.MySqlConnectStr()), &gorm.Config{
+	var (
+		db      *gorm.DB
+		err     error
+		backoff = config.RetryBackoff
+	)
+	for attempt := 0; ; attempt++ {
+		db, err = open(config)
+		if err == nil || attempt >= config.ConnectRetries {
+			break
+		}
+		logrus.Warnf("database not ready (attempt %d/%d), retrying in %s: %v",
+			attempt+1, config.ConnectRetries+1, backoff, err)
+		time.Sleep(backoff)
+		if backoff *= 2; config.RetryMaxBackoff > 0 && backoff > config.RetryMaxBackoff {
+			backoff = config.RetryMaxBackoff
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if config.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+
+	if len(config.ReplicaDSNs) > 0 {
+		replicas := make([]gorm.Dialector, len(config.ReplicaDSNs))
+		for i, dsn := range config.ReplicaDSNs {
+			replicas[i] = dialector(config.Driver, dsn)
+		}
+		resolver := dbresolver.Register(dbresolver.Config{
+			Replicas: replicas,
+			Policy:   dbresolver.RandomPolicy{},
+		})
+		if config.MaxOpenConns > 0 {
+			resolver.SetMaxOpenConns(config.MaxOpenConns)
+		}
+		if config.MaxIdleConns > 0 {
+			resolver.SetMaxIdleConns(config.MaxIdleConns)
+		}
+		resolver.SetConnMaxLifetime(config.ConnMaxLifetime)
+		resolver.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+		if err := db.Use(resolver); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := registerMetrics(db); err != nil {
+		return nil, err
+	}
+	if err := registerTracing(db); err != nil {
+		return nil, err
+	}
+	if config.QueryTimeout > 0 {
+		if err := registerQueryTimeout(db, config.QueryTimeout); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+func open(config *configs.DatabaseConfig) (*gorm.DB, error) {
+	db, err := gorm.Open(Dialector(config), &gorm.Config{
 		SkipDefaultTransaction: true,
 		PrepareStmt:            true,
 		TranslateError:         true,
 	})
 	if err != nil {
-		return nil, db.Error
+		return nil, err
+	}
+	// prepared statement pools skip gorm's automatic ping
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, err
 	}
 	return db, nil
 }
 
-func Migrate(db *gorm.DB) error {
-	return db.AutoMigrate(
-		&User{},
-		&Blog{},
-		&Book{},
+func registerQueryTimeout(db *gorm.DB, timeout time.Duration) error {
+	before := func(tx *gorm.DB) {
+		ctx, cancel := context.WithTimeout(tx.Statement.Context, timeout)
+		tx.Statement.Context = ctx
+		tx.InstanceSet("query_timeout:cancel", cancel)
+	}
+	after := func(tx *gorm.DB) {
+		if cancel, found := tx.InstanceGet("query_timeout:cancel"); found {
+			cancel.(context.CancelFunc)()
+		}
+	}
+	// row callbacks are skipped as their rows outlive the callback chain
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("query_timeout:before_create", before),
+		callbacks.Create().After("gorm:create").Register("query_timeout:after_create", after),
+		callbacks.Query().Before("gorm:query").Register("query_timeout:before_query", before),
+		callbacks.Query().After("gorm:query").Register("query_timeout:after_query", after),
+		callbacks.Update().Before("gorm:update").Register("query_timeout:before_update", before),
+		callbacks.Update().After("gorm:update").Register("query_timeout:after_update", after),
+		callbacks.Delete().Before("gorm:delete").Register("query_timeout:before_delete", before),
+		callbacks.Delete().After("gorm:delete").Register("query_timeout:after_delete", after),
+		callbacks.Raw().Before("gorm:raw").Register("query_timeout:before_raw", before),
+		callbacks.Raw().After("gorm:raw").Register("query_timeout:after_raw", after),
 	)
 }
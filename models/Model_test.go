@@ -0,0 +1,417 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rizghz/api/configs"
+	"github.com/rizghz/api/helpers"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// newTestDB opens a private in-memory SQLite database. The tests empty the
+// tables they use, so other databases are only used when named explicitly
+// by TEST_DATABASE_DRIVER and TEST_DATABASE_DSN, never through DB_*.
+func newTestDB(t *testing.T) *gorm.DB {
+	conf := &configs.DatabaseConfig{
+		Driver:       "sqlite",
+		DSN:          fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ToLower(t.Name())),
+		QueryTimeout: 5 * time.Second,
+	}
+	if driver := os.Getenv("TEST_DATABASE_DRIVER"); driver != "" {
+		dsn := os.Getenv("TEST_DATABASE_DSN")
+		if dsn == "" {
+			t.Fatal("[err]: TEST_DATABASE_DSN is required with TEST_DATABASE_DRIVER")
+		}
+		conf = &configs.DatabaseConfig{Driver: driver, DSN: dsn, QueryTimeout: 5 * time.Second}
+	}
+	db, err := Init(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM blogs")
+		db.Exec("DELETE FROM books")
+		db.Exec("DELETE FROM users")
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestInit(t *testing.T) {
+	t.Run("Invalid Init (retries exhausted)", func(t *testing.T) {
+		start := time.Now()
+		_, err := Init(&configs.DatabaseConfig{
+			Driver:          "postgres",
+			DSN:             "host=127.0.0.1 port=1 user=x dbname=x connect_timeout=1",
+			ConnectRetries:  2,
+			RetryBackoff:    10 * time.Millisecond,
+			RetryMaxBackoff: 15 * time.Millisecond,
+		})
+		assert.Error(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 25*time.Millisecond)
+	})
+
+	t.Run("Invalid Init (query timeout)", func(t *testing.T) {
+		db, err := Init(&configs.DatabaseConfig{
+			Driver:       "sqlite",
+			DSN:          "file:timeout?mode=memory&cache=shared",
+			QueryTimeout: time.Nanosecond,
+		})
+		if assert.NoError(t, err) {
+			assert.ErrorIs(t, db.Exec("SELECT 1").Error, context.DeadlineExceeded)
+		}
+	})
+}
+
+func TestResolver(t *testing.T) {
+	replica, err := Init(&configs.DatabaseConfig{Driver: "sqlite", DSN: "file:replica?mode=memory&cache=shared"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateUp(replica); err != nil {
+		t.Fatal(err)
+	}
+	db, err := Init(&configs.DatabaseConfig{
+		Driver:      "sqlite",
+		DSN:         "file:primary?mode=memory&cache=shared",
+		ReplicaDSNs: []string{"file:replica?mode=memory&cache=shared"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	model := NewBookModel(db)
+
+	t.Run("Read From Replica", func(t *testing.T) {
+		ctx := context.Background()
+		ok, _ := model.Create(ctx, &Book{Title: "Buku A", Author: "Author A"})
+		assert.True(t, ok)
+		assert.Empty(t, model.Get(ctx))
+	})
+
+	t.Run("Read Your Writes", func(t *testing.T) {
+		ctx, consistency := helpers.WithConsistency(context.Background())
+		ok, _ := model.Create(ctx, &Book{Title: "Buku B", Author: "Author B"})
+		assert.True(t, ok)
+		assert.True(t, consistency.Wrote())
+		assert.Len(t, model.Get(ctx), 2)
+	})
+
+	t.Run("Ping Replica", func(t *testing.T) {
+		ctx := context.Background()
+		assert.NoError(t, PingReplica(ctx, db))
+		assert.Error(t, PingReplica(ctx, replica), "a database without replicas has none to check")
+
+		pools, err := replicas(db)
+		if assert.NoError(t, err) && assert.Len(t, pools, 1) {
+			pools[0].Close()
+		}
+		assert.ErrorContains(t, PingReplica(ctx, db), "replica 1")
+		assert.NoError(t, Ping(ctx, db), "the primary is still up")
+	})
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(trace.NewNoopTracerProvider()) })
+
+	model := NewBookModel(newTestDB(t))
+	model.Get(context.Background())
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	if assert.Contains(t, spans, "BookModel.Get") && assert.Contains(t, spans, "sql.select") {
+		parent, child := spans["BookModel.Get"], spans["sql.select"]
+		assert.Equal(t, parent.SpanContext().SpanID(), child.Parent().SpanID())
+		assert.Contains(t, child.Attributes(), semconv.DBSQLTableKey.String("books"))
+	}
+}
+
+func TestMigration(t *testing.T) {
+	db := newTestDB(t)
+
+	pending, err := PendingMigrations(db)
+	if assert.NoError(t, err) {
+		assert.Zero(t, pending)
+	}
+
+	migrations, _ := Migrations("sqlite")
+	reverted, err := MigrateDown(db, len(migrations))
+	if assert.NoError(t, err) && assert.Len(t, reverted, len(migrations)) {
+		pending, _ = PendingMigrations(db)
+		assert.Equal(t, len(migrations), pending)
+		assert.False(t, db.Migrator().HasTable(&Book{}))
+	}
+
+	applied, err := MigrateUp(db)
+	if assert.NoError(t, err) {
+		assert.Equal(t, migrations, applied)
+		assert.True(t, db.Migrator().HasTable(&Book{}))
+	}
+
+	states, err := MigrationStatus(db)
+	if assert.NoError(t, err) && assert.NotEmpty(t, states) {
+		assert.True(t, states[len(states)-1].Applied)
+	}
+}
+
+func TestCheckMigrations(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Valid Check Migrations", func(t *testing.T) {
+		assert.NoError(t, CheckMigrations(ctx, newTestDB(t)))
+	})
+
+	t.Run("Invalid Check Migrations (pending)", func(t *testing.T) {
+		db := newTestDB(t)
+		MigrateDown(db, 1)
+		assert.ErrorContains(t, CheckMigrations(ctx, db), "1 pending migration(s)")
+	})
+
+	t.Run("Invalid Check Migrations (never migrated)", func(t *testing.T) {
+		db, err := Init(&configs.DatabaseConfig{Driver: "sqlite", DSN: "file:unmigrated?mode=memory&cache=shared"})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Error(t, CheckMigrations(ctx, db))
+		assert.False(t, db.Migrator().HasTable(&SchemaMigration{}), "probes never create the table")
+	})
+}
+
+func TestMigrationBaseline(t *testing.T) {
+	db := newTestDB(t)
+	migrations, _ := Migrations("sqlite")
+	if _, err := MigrateDown(db, len(migrations)); !assert.NoError(t, err) {
+		return
+	}
+	// databases created by AutoMigrate before the migrations had books
+	// without isbn and no schema_migrations rows
+	type Book struct {
+		gorm.Model
+		Title     string
+		Author    string
+		Publisher string
+	}
+	if !assert.NoError(t, db.AutoMigrate(&User{}, &Blog{}, &Book{})) {
+		return
+	}
+	db.Exec("INSERT INTO books (title, author) VALUES ('Buku A', 'Author A')")
+
+	if _, err := MigrateUp(db); assert.NoError(t, err) {
+		assert.True(t, db.Migrator().HasColumn("books", "isbn"))
+		books := NewBookModel(db).Get(context.Background())
+		if assert.Len(t, books, 1) {
+			assert.Equal(t, "Buku A", books[0].Title)
+		}
+	}
+}
+
+func TestUserModel(t *testing.T) {
+	ctx := context.Background()
+	model := NewUserModel(newTestDB(t), &configs.JwtConfig{Secret: "rahasia", Expiry: time.Hour})
+
+	ok, user := model.Create(ctx, &User{Name: "User A", Email: "a@mail.com", Password: "A123"})
+	assert.True(t, ok)
+	assert.NotZero(t, user.ID)
+
+	id := int(user.ID)
+	assert.Equal(t, "User A", model.Find(ctx, &id).Name)
+	assert.Len(t, model.Get(ctx), 1)
+
+	user.Name = "User B"
+	ok, _ = model.Update(ctx, user)
+	assert.True(t, ok)
+	assert.Equal(t, "User B", model.Find(ctx, &id).Name)
+
+	res, err := model.Check(ctx, &User{Email: "a@mail.com", Password: "A123"})
+	if assert.NoError(t, err) {
+		assert.NotEmpty(t, res.Token)
+	}
+	_, err = model.Check(ctx, &User{Email: "a@mail.com", Password: "salah"})
+	assert.Error(t, err)
+
+	assert.True(t, model.Delete(ctx, &id))
+	assert.Nil(t, model.Find(ctx, &id))
+}
+
+func TestBookModel(t *testing.T) {
+	ctx := context.Background()
+	model := NewBookModel(newTestDB(t))
+
+	ok, book := model.Create(ctx, &Book{ISBN: "9786020000001", Title: "Buku A", Author: "Author A"})
+	assert.True(t, ok)
+	model.Create(ctx, &Book{Title: "Buku B", Author: "Author B"})
+
+	id := int(book.ID)
+	assert.Equal(t, "Buku A", model.Find(ctx, &id).Title)
+	assert.Len(t, model.Get(ctx), 2)
+
+	book.Publisher = "Publisher A"
+	ok, _ = model.Update(ctx, book)
+	assert.True(t, ok)
+	assert.Equal(t, "Publisher A", model.Find(ctx, &id).Publisher)
+
+	assert.NotNil(t, model.Duplicate(ctx, &Book{ISBN: "9786020000001"}))
+	assert.NotNil(t, model.Duplicate(ctx, &Book{Title: "Buku B", Author: "Author B"}))
+	assert.NotNil(t, model.Duplicate(ctx, &Book{Title: "buku b", Author: "AUTHOR B"}))
+	assert.Nil(t, model.Duplicate(ctx, &Book{Title: "Buku C", Author: "Author C"}))
+
+	titles := []string{}
+	err := model.Each(ctx, func(book *Book) error {
+		titles = append(titles, book.Title)
+		return nil
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"Buku A", "Buku B"}, titles)
+	}
+
+	assert.True(t, model.Delete(ctx, &id))
+	assert.Nil(t, model.Find(ctx, &id))
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Nil(t, model.Get(cancelled))
+	ok, _ = model.Create(cancelled, &Book{Title: "Buku C", Author: "Author C"})
+	assert.False(t, ok)
+}
+
+func TestBlogModel(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	_, user := NewUserModel(db, &configs.JwtConfig{}).Create(ctx, &User{Name: "User A", Email: "a@mail.com"})
+	model := NewBlogModel(db)
+
+	ok, blog := model.Create(ctx, &Blog{Title: "Title A", Content: "Content A", UserID: user.ID})
+	assert.True(t, ok)
+
+	id := int(blog.ID)
+	assert.Equal(t, "Title A", model.Find(ctx, &id).Title)
+	assert.Len(t, model.Get(ctx), 1)
+
+	assert.Len(t, model.GetByUsers(ctx, []uint{user.ID, user.ID + 1})[user.ID], 1)
+	assert.Len(t, NewUserModel(db, &configs.JwtConfig{}).GetByIDs(ctx, []uint{user.ID}), 1)
+
+	blog.Content = "Content B"
+	ok, _ = model.Update(ctx, blog)
+	assert.True(t, ok)
+	assert.Equal(t, "Content B", model.Find(ctx, &id).Content)
+
+	contents := []string{}
+	err := model.Each(ctx, func(blog *Blog) error {
+		contents = append(contents, blog.Content)
+		return nil
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"Content B"}, contents)
+	}
+
+	assert.True(t, model.Delete(ctx, &id))
+	assert.Nil(t, model.Find(ctx, &id))
+
+	// a nested author is read only and never creates or changes a user
+	nested := &User{Name: "User B", Email: "b@mail.com", Password: "rahasia"}
+	ok, blog = model.Create(ctx, &Blog{Title: "Title B", Content: "Content B", UserID: user.ID, Author: nested})
+	assert.True(t, ok)
+	blog.Author = &User{Name: "User C", Email: "c@mail.com"}
+	ok, _ = model.Update(ctx, blog)
+	assert.True(t, ok)
+	var users int64
+	db.Model(&User{}).Count(&users)
+	assert.EqualValues(t, 1, users)
+	id = int(blog.ID)
+	assert.Equal(t, user.ID, model.Find(ctx, &id).UserID)
+}
+
+func TestSelection(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	users := NewUserModel(db, &configs.JwtConfig{})
+	_, user := users.Create(ctx, &User{Name: "User A", Email: "a@mail.com", Password: "secret"})
+	blogs := NewBlogModel(db)
+	_, blog := blogs.Create(ctx, &Blog{Title: "Title A", Content: "Content A", UserID: user.ID})
+
+	userID, blogID := int(user.ID), int(blog.ID)
+	found := users.Find(WithSelection(ctx, Selection{Fields: []string{"name"}, Include: []string{"blogs"}}), &userID)
+	if assert.NotNil(t, found) {
+		assert.Equal(t, "User A", found.Name)
+		assert.Empty(t, found.Email)
+		assert.Empty(t, found.Password)
+		assert.Len(t, found.Blogs, 1)
+	}
+
+	// the foreign key is selected even when it is left out of the fields
+	observed := blogs.Find(WithSelection(ctx, Selection{Fields: []string{"title"}, Include: []string{"author"}}), &blogID)
+	if assert.NotNil(t, observed) && assert.NotNil(t, observed.Author) {
+		assert.Empty(t, observed.Content)
+		assert.Equal(t, "User A", observed.Author.Name)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	books := NewBookModel(db)
+	books.Create(ctx, &Book{Title: "Learning Go", Author: "Jon Bodner", Publisher: "O'Reilly"})
+	books.Create(ctx, &Book{Title: "The C Language", Author: "Go Team", Publisher: "Prentice"})
+	books.Create(ctx, &Book{Title: "100% Rust", Author: "Jane", Publisher: "Manning"})
+	_, user := NewUserModel(db, &configs.JwtConfig{}).Create(ctx, &User{Name: "User A"})
+	blogs := NewBlogModel(db)
+	blogs.Create(ctx, &Blog{Title: "Notes", Content: strings.Repeat("lorem ", 50) + "why <Go> is simple", UserID: user.ID})
+
+	result := books.Search(ctx, SearchQuery{Text: "go", Page: 1, PerPage: 10})
+	if assert.NotNil(t, result) && assert.Len(t, result.Hits, 2) {
+		assert.EqualValues(t, 2, result.Total)
+		// a match in the title outranks a match in the author
+		assert.Equal(t, "Learning Go", result.Hits[0].Record.(Book).Title)
+		assert.Equal(t, "Learning <mark>Go</mark>", result.Hits[0].Snippet)
+	}
+
+	paged := books.Search(ctx, SearchQuery{Text: "go", Page: 2, PerPage: 1})
+	if assert.NotNil(t, paged) && assert.Len(t, paged.Hits, 1) {
+		assert.EqualValues(t, 2, paged.Total)
+		assert.Equal(t, "The C Language", paged.Hits[0].Record.(Book).Title)
+	}
+
+	// LIKE wildcards in the query are matched literally
+	assert.Len(t, books.Search(ctx, SearchQuery{Text: "100%", Page: 1, PerPage: 10}).Hits, 1)
+	assert.Empty(t, books.Search(ctx, SearchQuery{Text: "_", Page: 1, PerPage: 10}).Hits)
+
+	found := blogs.Search(ctx, SearchQuery{Text: "go", Page: 1, PerPage: 10})
+	if assert.NotNil(t, found) && assert.Len(t, found.Hits, 1) {
+		assert.True(t, strings.HasPrefix(found.Hits[0].Snippet, "…"))
+		assert.Contains(t, found.Hits[0].Snippet, "why &lt;<mark>Go</mark>&gt; is simple")
+	}
+
+	// lower casing "Ⱥ" takes a byte more, offsets must come from the text itself
+	text := strings.Repeat("Ⱥ", 200) + " foo"
+	assert.NotPanics(t, func() {
+		assert.True(t, strings.HasSuffix(snippet([]string{text}, []string{"foo"}), " <mark>foo</mark>"))
+	})
+	assert.Equal(t, "<mark>ⱥȺ</mark> b", snippet([]string{"ⱥȺ b"}, []string{"ⱥⱥ"}))
+
+	merged := MergeResults(SearchQuery{Page: 1, PerPage: 2}, result, found)
+	assert.EqualValues(t, 3, merged.Total)
+	assert.Len(t, merged.Hits, 2)
+}
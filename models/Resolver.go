@@ -0,0 +1,25 @@
+package models
+
+import (
+	"context"
+
+	"github.com/rizghz/api/helpers"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// reader routes to a replica unless the request already wrote data
+func reader(ctx context.Context, db *gorm.DB) *gorm.DB {
+	tx := db.WithContext(ctx)
+	if consistency := helpers.GetConsistency(ctx); consistency != nil && consistency.Primary() {
+		return tx.Clauses(dbresolver.Write)
+	}
+	return tx
+}
+
+func writer(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if consistency := helpers.GetConsistency(ctx); consistency != nil {
+		consistency.MarkWrite()
+	}
+	return db.WithContext(ctx)
+}
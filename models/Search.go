@@ -0,0 +1,323 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rizghz/api/helpers"
+	"gorm.io/gorm"
+)
+
+const (
+	SearchPerPage    = 20
+	SearchMaxPerPage = 100
+	SearchMaxPage    = 100
+	SearchMaxTerms   = 10
+	snippetWidth     = 160
+)
+
+// SearchQuery is a full-text query and the page of hits asked for, filters
+// narrow the hits to the values of facets and are only used by an index
+type SearchQuery struct {
+	Text    string
+	Page    int
+	PerPage int
+	Filters map[string]string
+}
+
+// Terms are the words of the query, lower cased and without duplicates
+func (q SearchQuery) Terms() []string {
+	terms, seen := []string{}, map[string]bool{}
+	for _, term := range strings.Fields(strings.ToLower(q.Text)) {
+		if !seen[term] && len(terms) < SearchMaxTerms {
+			terms, seen[term] = append(terms, term), true
+		}
+	}
+	return terms
+}
+
+func (q SearchQuery) offset() int {
+	return (q.Page - 1) * q.PerPage
+}
+
+// SearchHit is a record matching a query, the snippet is HTML escaped with
+// the matching words wrapped in <mark>
+type SearchHit struct {
+	Resource string  `json:"resource"`
+	ID       uint    `json:"id"`
+	Score    float64 `json:"score"`
+	Snippet  string  `json:"snippet"`
+	Record   any     `json:"record"`
+}
+
+type SearchResult struct {
+	Hits    []SearchHit        `json:"hits"`
+	Total   int64              `json:"total"`
+	Page    int                `json:"page"`
+	PerPage int                `json:"per_page"`
+	Facets  map[string][]Facet `json:"facets,omitempty"`
+}
+
+// Facet counts the hits sharing a value
+type Facet struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// SearchFilters are the facets an index narrows its hits to, type is one
+// of books or blogs
+var SearchFilters = []string{"type", "author", "publisher", "year"}
+
+// ISearchIndex searches a dedicated index instead of the database
+type ISearchIndex interface {
+	Search(ctx context.Context, query SearchQuery) *SearchResult
+}
+
+// Hook is told about the records written through a model, after their
+// transaction committed. Deleted records only carry their id.
+type Hook interface {
+	AfterSave(ctx context.Context, record any)
+	AfterDelete(ctx context.Context, record any)
+}
+
+// MergeResults ranks the hits of several resources together and keeps the
+// page of query, each result must hold the hits up to the end of that page
+func MergeResults(query SearchQuery, results ...*SearchResult) *SearchResult {
+	merged := &SearchResult{Hits: []SearchHit{}, Page: query.Page, PerPage: query.PerPage}
+	for _, result := range results {
+		merged.Hits = append(merged.Hits, result.Hits...)
+		merged.Total += result.Total
+	}
+	sort.SliceStable(merged.Hits, func(i, j int) bool {
+		return merged.Hits[i].Score > merged.Hits[j].Score
+	})
+	if offset := query.offset(); offset < len(merged.Hits) {
+		merged.Hits = merged.Hits[offset:]
+	} else {
+		merged.Hits = merged.Hits[:0]
+	}
+	if len(merged.Hits) > query.PerPage {
+		merged.Hits = merged.Hits[:query.PerPage]
+	}
+	return merged
+}
+
+// searchable describes the columns a table is searched on and their weight
+// in the LIKE fallback
+type searchable struct {
+	table   string
+	columns []string
+	weights []float64
+}
+
+type scored struct {
+	ID    uint
+	Score float64
+}
+
+// search runs query against the full-text index of the driver in use and
+// returns the ids of the page of hits, best first
+func search(ctx context.Context, db *gorm.DB, model any, spec searchable, query SearchQuery) ([]scored, int64, error) {
+	terms := query.Terms()
+	if len(terms) == 0 {
+		return nil, 0, nil
+	}
+	var (
+		where string
+		score string
+		args  []any
+	)
+	columns := strings.Join(spec.columns, ", ")
+	switch db.Dialector.Name() {
+	case "mysql":
+		where = fmt.Sprintf("MATCH (%s) AGAINST (? IN NATURAL LANGUAGE MODE)", columns)
+		score, args = where, []any{query.Text}
+	case "postgres":
+		where = "search @@ plainto_tsquery('simple', ?)"
+		score, args = "ts_rank(search, plainto_tsquery('simple', ?))", []any{query.Text}
+	default:
+		// every term must appear in a column, scored by the weight of the columns it is found in
+		conditions, cases := []string{}, []string{}
+		for _, term := range terms {
+			pattern := "%" + escapeLike(term) + "%"
+			matches := []string{}
+			for i, column := range spec.columns {
+				matches = append(matches, fmt.Sprintf("%s LIKE ? ESCAPE '\\'", column))
+				cases = append(cases, fmt.Sprintf("CASE WHEN %s LIKE ? ESCAPE '\\' THEN %g ELSE 0 END", column, spec.weights[i]))
+				args = append(args, pattern)
+			}
+			conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+		}
+		where, score = strings.Join(conditions, " AND "), strings.Join(cases, " + ")
+	}
+
+	var total int64
+	if err := reader(ctx, db).Model(model).Where(where, args...).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	hits := []scored{}
+	err := reader(ctx, db).Model(model).
+		Select(fmt.Sprintf("id, %s AS score", score), args...).
+		Where(where, args...).
+		Order("score DESC, id").
+		Limit(query.PerPage).Offset(query.offset()).
+		Scan(&hits).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return hits, total, nil
+}
+
+func escapeLike(term string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+}
+
+// searchRecords loads the records of the hits and builds the result,
+// describe returns the id of a record and its texts to cut snippets from
+func searchRecords[T any](ctx context.Context, db *gorm.DB, spec searchable, query SearchQuery, describe func(T) (uint, []string)) *SearchResult {
+	result := &SearchResult{Hits: []SearchHit{}, Page: query.Page, PerPage: query.PerPage}
+	hits, total, err := search(ctx, db, new(T), spec, query)
+	if err != nil {
+		helpers.Log(ctx).Error(err.Error())
+		return nil
+	}
+	result.Total = total
+	if len(hits) == 0 {
+		return result
+	}
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	records := []T{}
+	if err := reader(ctx, db).Where("id IN ?", ids).Find(&records).Error; err != nil {
+		helpers.Log(ctx).Error(err.Error())
+		return nil
+	}
+	byID, texts := make(map[uint]T, len(records)), make(map[uint][]string, len(records))
+	for _, record := range records {
+		id, text := describe(record)
+		byID[id], texts[id] = record, text
+	}
+	terms := query.Terms()
+	for _, hit := range hits {
+		record, found := byID[hit.ID]
+		if !found {
+			continue
+		}
+		result.Hits = append(result.Hits, SearchHit{
+			Resource: spec.table,
+			ID:       hit.ID,
+			Score:    hit.Score,
+			Snippet:  snippet(texts[hit.ID], terms),
+			Record:   record,
+		})
+	}
+	return result
+}
+
+// snippet cuts the first text containing a term around its first match and
+// marks every term found in the cut
+func snippet(texts []string, terms []string) string {
+	for _, text := range texts {
+		start := -1
+		for _, term := range terms {
+			if i := indexFold(text, term); i >= 0 && (start < 0 || i < start) {
+				start = i
+			}
+		}
+		if start < 0 {
+			continue
+		}
+		from, to := window(text, start, snippetWidth)
+		return ellipsis(from > 0) + highlight(text[from:to], terms) + ellipsis(to < len(text))
+	}
+	if len(texts) > 0 {
+		from, to := window(texts[0], 0, snippetWidth)
+		return html.EscapeString(texts[0][from:to]) + ellipsis(to < len(texts[0]))
+	}
+	return ""
+}
+
+// window returns the bounds of about width bytes of text around start,
+// moved to rune boundaries
+func window(text string, start int, width int) (int, int) {
+	from := start - width/4
+	if from < 0 {
+		from = 0
+	}
+	if from > len(text) {
+		from = len(text)
+	}
+	to := from + width
+	if to > len(text) {
+		to = len(text)
+	}
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+	return from, to
+}
+
+// indexFold is strings.Index under case folding, returning a byte offset
+// of text itself as lower casing may change byte lengths outside ASCII
+func indexFold(text string, term string) int {
+	for i := range text {
+		if prefixFold(text[i:], term) > 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// prefixFold returns the length in bytes of the prefix of text matching term
+// under case folding, 0 when text does not start with term
+func prefixFold(text string, term string) int {
+	n := 0
+	for _, want := range term {
+		if n >= len(text) {
+			return 0
+		}
+		got, size := utf8.DecodeRuneInString(text[n:])
+		if got != want && !strings.EqualFold(string(got), string(want)) {
+			return 0
+		}
+		n += size
+	}
+	return n
+}
+
+func highlight(text string, terms []string) string {
+	out := strings.Builder{}
+	for i := 0; i < len(text); {
+		matched := 0
+		for _, term := range terms {
+			if n := prefixFold(text[i:], term); n > matched {
+				matched = n
+			}
+		}
+		if matched > 0 {
+			out.WriteString("<mark>" + html.EscapeString(text[i:i+matched]) + "</mark>")
+			i += matched
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		out.WriteString(html.EscapeString(text[i : i+size]))
+		i += size
+	}
+	return out.String()
+}
+
+func ellipsis(cut bool) string {
+	if cut {
+		return "…"
+	}
+	return ""
+}
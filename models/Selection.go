@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// IncludeLimit caps the rows preloaded for a single relation
+const IncludeLimit = 100
+
+// Fields maps the names clients may list in ?fields= to their columns.
+// Names are matched by FieldName, so created_at selects CreatedAt.
+type Fields map[string]string
+
+// Relation describes a relation clients may list in ?include=, the columns
+// of the parent it needs to be loaded and the fields it is rendered with
+type Relation struct {
+	Name   string
+	Keys   []string
+	Fields Fields
+}
+
+type Relations map[string]Relation
+
+// Selection is the sparse fieldset and the relations asked for by a read
+type Selection struct {
+	Fields  []string
+	Include []string
+}
+
+type selectionKey struct{}
+
+func WithSelection(ctx context.Context, selection Selection) context.Context {
+	return context.WithValue(ctx, selectionKey{}, selection)
+}
+
+func GetSelection(ctx context.Context) Selection {
+	selection, _ := ctx.Value(selectionKey{}).(Selection)
+	return selection
+}
+
+// FieldName normalises a field so JSON keys, columns and query names match
+func FieldName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "_", ""))
+}
+
+// selected applies the selection stored in ctx, which the controllers have
+// already checked against fields and relations
+func selected(ctx context.Context, fields Fields, relations Relations) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		selection := GetSelection(ctx)
+		if len(selection.Fields) > 0 {
+			// the primary key is always loaded to identify rows and preload relations
+			columns, seen := []string{"id"}, map[string]bool{"id": true}
+			add := func(column string) {
+				if column != "" && !seen[column] {
+					columns, seen[column] = append(columns, column), true
+				}
+			}
+			for _, field := range selection.Fields {
+				add(fields[FieldName(field)])
+			}
+			for _, include := range selection.Include {
+				for _, key := range relations[include].Keys {
+					add(key)
+				}
+			}
+			db = db.Select(columns)
+		}
+		for _, include := range selection.Include {
+			if relation, found := relations[include]; found {
+				db = db.Preload(relation.Name, func(db *gorm.DB) *gorm.DB {
+					return db.Order("id").Limit(IncludeLimit)
+				})
+			}
+		}
+		return db
+	}
+}
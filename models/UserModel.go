@@ -1,8 +1,11 @@
 package models
 
 import (
+	"context"
+
+	"github.com/rizghz/api/configs"
+	"github.com/rizghz/api/helpers"
 	"github.com/rizghz/api/routes/middleware"
-	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
@@ -15,102 +18,138 @@ type User struct {
 	Blogs    []Blog `json:"blogs"`
 }
 
+// UserFields and UserRelations are the whitelist of ?fields= and ?include=,
+// the password and the token can never be selected
+var (
+	UserFields = Fields{
+		"id": "id", "createdat": "created_at", "updatedat": "updated_at",
+		"name": "name", "email": "email",
+	}
+	UserRelations = Relations{"blogs": {Name: "Blogs", Fields: BlogFields}}
+)
+
 type UserModel struct {
-	db *gorm.DB
+	db  *gorm.DB
+	jwt *configs.JwtConfig
 }
 
 type IUserModel interface {
-	Get() []User
-	Find(key *int) *User
-	Create(user *User) (bool, *User)
-	Update(user *User) (bool, *User)
-	Delete(key *int) bool
-	Check(user *User) (*User, error)
+	Get(ctx context.Context) []User
+	Find(ctx context.Context, key *int) *User
+	Create(ctx context.Context, user *User) (bool, *User)
+	Update(ctx context.Context, user *User) (bool, *User)
+	Delete(ctx context.Context, key *int) bool
+	Check(ctx context.Context, user *User) (*User, error)
+	GetByIDs(ctx context.Context, keys []uint) []User
 }
 
-func NewUserModel(db *gorm.DB) IUserModel {
+func NewUserModel(db *gorm.DB, jwt *configs.JwtConfig) IUserModel {
 	return &UserModel{
-		db: db,
+		db:  db,
+		jwt: jwt,
 	}
 }
 
-func (m *UserModel) Get() []User {
+func (m *UserModel) Get(ctx context.Context) []User {
+	ctx, span := observe(ctx, "UserModel.Get")
+	defer span.End()
 	users := []User{}
-	if err := m.db.Find(&users).Error; err != nil {
-		logrus.Error(err.Error())
+	if err := reader(ctx, m.db).Scopes(selected(ctx, UserFields, UserRelations)).Find(&users).Error; err != nil {
+		helpers.Log(ctx).Error(err.Error())
 		return nil
 	}
 	return users
 }
 
-func (m *UserModel) Find(key *int) *User {
+func (m *UserModel) Find(ctx context.Context, key *int) *User {
+	ctx, span := observe(ctx, "UserModel.Find")
+	defer span.End()
 	user := User{}
-	if err := m.db.First(&user, key).Error; err != nil {
-		logrus.Error(err.Error())
+	if err := reader(ctx, m.db).Scopes(selected(ctx, UserFields, UserRelations)).First(&user, key).Error; err != nil {
+		helpers.Log(ctx).Error(err.Error())
 		return nil
 	}
 	return &user
 }
 
-func (m *UserModel) Create(user *User) (bool, *User) {
-	err := m.db.Transaction(func(tx *gorm.DB) error {
+func (m *UserModel) GetByIDs(ctx context.Context, keys []uint) []User {
+	ctx, span := observe(ctx, "UserModel.GetByIDs")
+	defer span.End()
+	users := []User{}
+	if err := reader(ctx, m.db).Where("id IN ?", keys).Find(&users).Error; err != nil {
+		helpers.Log(ctx).Error(err.Error())
+		return nil
+	}
+	return users
+}
+
+func (m *UserModel) Create(ctx context.Context, user *User) (bool, *User) {
+	ctx, span := observe(ctx, "UserModel.Create")
+	defer span.End()
+	err := writer(ctx, m.db).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(user).Error; err != nil {
-			logrus.Error(err.Error())
+			helpers.Log(ctx).Error(err.Error())
 			return err
 		}
 		return nil
 	})
 	if err != nil {
-		logrus.Error(err.Error())
+		helpers.Log(ctx).Error(err.Error())
 		return false, nil
 	}
 	return true, user
 }
 
-func (m *UserModel) Update(user *User) (bool, *User) {
-	err := m.db.Transaction(func(tx *gorm.DB) error {
+func (m *UserModel) Update(ctx context.Context, user *User) (bool, *User) {
+	ctx, span := observe(ctx, "UserModel.Update")
+	defer span.End()
+	err := writer(ctx, m.db).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Save(user).Error; err != nil {
-			logrus.Error(err.Error())
+			helpers.Log(ctx).Error(err.Error())
 			return err
 		}
 		return nil
 	})
 	if err != nil {
-		logrus.Error(err.Error())
+		helpers.Log(ctx).Error(err.Error())
 		return false, nil
 	}
 	return true, user
 }
 
-func (m *UserModel) Delete(key *int) bool {
-	err := m.db.Transaction(func(tx *gorm.DB) error {
+func (m *UserModel) Delete(ctx context.Context, key *int) bool {
+	ctx, span := observe(ctx, "UserModel.Delete")
+	defer span.End()
+	err := writer(ctx, m.db).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Delete(&User{}, key).Error; err != nil {
-			logrus.Error(err.Error())
+			helpers.Log(ctx).Error(err.Error())
 			return err
 		}
 		return nil
 	})
 	if err != nil {
-		logrus.Error(err.Error())
+		helpers.Log(ctx).Error(err.Error())
 		return false
 	}
 	return true
 }
 
-func (m *UserModel) Check(user *User) (*User, error) {
-	result := m.db.Where("email = ? AND password = ?", user.Email, user.Password).First(user)
+func (m *UserModel) Check(ctx context.Context, user *User) (*User, error) {
+	ctx, span := observe(ctx, "UserModel.Check")
+	defer span.End()
+	result := reader(ctx, m.db).Where("email = ? AND password = ?", user.Email, user.Password).First(user)
 	if err := result.Error; err != nil {
-		logrus.Error(err.Error())
+		helpers.Log(ctx).Error(err.Error())
 		return nil, err
 	}
 	var err error
-	user.Token, err = middleware.CreateToken(int(user.ID))
+	user.Token, err = middleware.CreateToken(m.jwt, int(user.ID))
 	if err != nil {
-		logrus.Error(err.Error())
+		helpers.Log(ctx).Error(err.Error())
 		return nil, err
 	}
-	if err := m.db.Save(user).Error; err != nil {
-		logrus.Error(err.Error())
+	if err := writer(ctx, m.db).Save(user).Error; err != nil {
+		helpers.Log(ctx).Error(err.Error())
 		return nil, err
 	}
 	return user, nil
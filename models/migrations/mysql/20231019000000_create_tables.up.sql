@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS users (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    created_at DATETIME(3) NULL,
+    updated_at DATETIME(3) NULL,
+    deleted_at DATETIME(3) NULL,
+    name LONGTEXT,
+    email LONGTEXT,
+    password LONGTEXT,
+    token LONGTEXT,
+    PRIMARY KEY (id),
+    INDEX idx_users_deleted_at (deleted_at)
+);
+
+CREATE TABLE IF NOT EXISTS blogs (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    created_at DATETIME(3) NULL,
+    updated_at DATETIME(3) NULL,
+    deleted_at DATETIME(3) NULL,
+    title LONGTEXT,
+    content LONGTEXT,
+    user_id BIGINT UNSIGNED,
+    PRIMARY KEY (id),
+    INDEX idx_blogs_deleted_at (deleted_at),
+    CONSTRAINT fk_users_blogs FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE IF NOT EXISTS books (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    created_at DATETIME(3) NULL,
+    updated_at DATETIME(3) NULL,
+    deleted_at DATETIME(3) NULL,
+    title LONGTEXT,
+    author LONGTEXT,
+    publisher LONGTEXT,
+    PRIMARY KEY (id),
+    INDEX idx_books_deleted_at (deleted_at)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE blogs DROP INDEX ft_blogs_search;
+
+ALTER TABLE books DROP INDEX ft_books_search;
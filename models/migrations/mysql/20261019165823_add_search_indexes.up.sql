@@ -0,0 +1,3 @@
+ALTER TABLE books ADD FULLTEXT INDEX ft_books_search (title, author, publisher);
+
+ALTER TABLE blogs ADD FULLTEXT INDEX ft_blogs_search (title, content);
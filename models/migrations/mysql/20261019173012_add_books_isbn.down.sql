@@ -0,0 +1,2 @@
+DROP INDEX idx_books_isbn ON books;
+ALTER TABLE books DROP COLUMN isbn;
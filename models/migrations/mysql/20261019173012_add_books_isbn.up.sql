@@ -0,0 +1,3 @@
+-- databases adopted from the AutoMigrate baseline have books without isbn
+ALTER TABLE books ADD COLUMN isbn VARCHAR(191) NULL AFTER deleted_at;
+CREATE INDEX idx_books_isbn ON books (isbn);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_blogs_search;
+ALTER TABLE blogs DROP COLUMN IF EXISTS search;
+
+DROP INDEX IF EXISTS idx_books_search;
+ALTER TABLE books DROP COLUMN IF EXISTS search;
@@ -0,0 +1,12 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS search TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(author, '')), 'B') ||
+    setweight(to_tsvector('simple', coalesce(publisher, '')), 'C')
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_books_search ON books USING GIN (search);
+
+ALTER TABLE blogs ADD COLUMN IF NOT EXISTS search TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(content, '')), 'B')
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_blogs_search ON blogs USING GIN (search);
@@ -0,0 +1,3 @@
+-- databases adopted from the AutoMigrate baseline have books without isbn
+ALTER TABLE books ADD COLUMN IF NOT EXISTS isbn TEXT;
+CREATE INDEX IF NOT EXISTS idx_books_isbn ON books (isbn);
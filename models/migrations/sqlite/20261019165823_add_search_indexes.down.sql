@@ -0,0 +1 @@
+-- sqlite searches with LIKE and needs no index
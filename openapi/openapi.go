@@ -0,0 +1,269 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Schema map[string]any
+
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]*PathItem `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]Schema `json:"schemas"`
+	SecuritySchemes map[string]Schema `json:"securitySchemes,omitempty"`
+}
+
+type PathItem struct {
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []Schema              `json:"parameters,omitempty"`
+	RequestBody Schema                `json:"requestBody,omitempty"`
+	Responses   map[string]Schema     `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+// Operation documents a single route; Body and Responses take zero values
+// of the DTOs exchanged, from which the schemas are derived.
+type Operation struct {
+	Summary    string
+	Tags       []string
+	Auth       bool
+	Hidden     bool
+	Deprecated bool
+	Query      []Param
+	Header     []Param
+	Form       []Param
+	Body       any
+	Responses  map[int]any
+	// MediaTypes are served besides JSON for the same request and response
+	// schemas
+	MediaTypes []string
+}
+
+type Param struct {
+	Name        string
+	Description string
+	Type        string
+	Format      string
+	Enum        []string
+	Required    bool
+}
+
+// Data is the {"message", "data"} envelope of helpers.FormatResponse
+type Data[T any] struct {
+	Message string `json:"message"`
+	Data    T      `json:"data"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+}
+
+// ErrorV2 is the error envelope of helpers.FormatResponseV2
+type ErrorV2 struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// EnvelopeV2 converts a Data or Error response body into its
+// helpers.FormatResponseV2 shape
+func EnvelopeV2(body any) any {
+	if _, found := body.(Error); found {
+		return ErrorV2{}
+	}
+	t := reflect.TypeOf(body)
+	if t == nil || t.Kind() != reflect.Struct || !strings.HasPrefix(t.Name(), "Data[") {
+		return body
+	}
+	data, _ := t.FieldByName("Data")
+	return reflect.New(reflect.StructOf([]reflect.StructField{
+		{Name: "Data", Type: data.Type, Tag: `json:"data"`},
+	})).Elem().Interface()
+}
+
+// File is a non-JSON response body in any of the listed media types
+type File struct {
+	Types []string
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+func Key(method string, path string) string {
+	return method + " " + path
+}
+
+// Undocumented lists the registered routes missing from docs
+func Undocumented(routes []*echo.Route, docs map[string]Operation) []string {
+	missing := []string{}
+	for _, route := range routes {
+		// echo adds catch-all routes to groups that have middleware
+		if route.Method == echo.RouteNotFound {
+			continue
+		}
+		if _, found := docs[Key(route.Method, route.Path)]; !found {
+			missing = append(missing, Key(route.Method, route.Path))
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func Build(info Info, routes []*echo.Route, docs map[string]Operation, security map[string]Schema) *Document {
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Paths:   map[string]map[string]*PathItem{},
+		Components: Components{
+			Schemas:         map[string]Schema{},
+			SecuritySchemes: security,
+		},
+	}
+	gen := &generator{schemas: doc.Components.Schemas}
+	for _, route := range routes {
+		op, found := docs[Key(route.Method, route.Path)]
+		if !found || op.Hidden {
+			continue
+		}
+		path := pathParam.ReplaceAllString(route.Path, "{$1}")
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = gen.operation(route, op)
+	}
+	return doc
+}
+
+func (g *generator) operation(route *echo.Route, op Operation) *PathItem {
+	item := &PathItem{
+		Summary:     op.Summary,
+		Tags:        op.Tags,
+		OperationID: operationID(route),
+		Responses:   map[string]Schema{},
+		Deprecated:  op.Deprecated,
+	}
+	for _, match := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+		item.Parameters = append(item.Parameters, Schema{
+			"name": match[1], "in": "path", "required": true,
+			"schema": Schema{"type": "integer", "minimum": 1},
+		})
+	}
+	for _, param := range op.Query {
+		item.Parameters = append(item.Parameters, Schema{
+			"name": param.Name, "in": "query", "required": param.Required,
+			"description": param.Description, "schema": param.schema(),
+		})
+	}
+	for _, param := range op.Header {
+		item.Parameters = append(item.Parameters, Schema{
+			"name": param.Name, "in": "header", "required": param.Required,
+			"description": param.Description, "schema": param.schema(),
+		})
+	}
+	if len(op.Form) > 0 {
+		properties, required := Schema{}, []string{}
+		for _, param := range op.Form {
+			properties[param.Name] = param.schema()
+			if param.Required {
+				required = append(required, param.Name)
+			}
+		}
+		form := Schema{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			form["required"] = required
+		}
+		item.RequestBody = Schema{
+			"required": true,
+			"content":  Schema{"multipart/form-data": Schema{"schema": form}},
+		}
+	} else if op.Body != nil {
+		item.RequestBody = Schema{
+			"required": true,
+			"content":  op.content(g.schema(reflect.TypeOf(op.Body))),
+		}
+	}
+	for status, body := range op.Responses {
+		response := Schema{"description": http.StatusText(status)}
+		switch body := body.(type) {
+		case nil:
+		case File:
+			content := Schema{}
+			for _, mime := range body.Types {
+				content[mime] = Schema{"schema": Schema{"type": "string", "format": "binary"}}
+			}
+			response["content"] = content
+		default:
+			response["content"] = op.content(g.schema(reflect.TypeOf(body)))
+		}
+		item.Responses[strconv.Itoa(status)] = response
+	}
+	if op.Auth {
+		item.Security = []map[string][]string{{"bearerAuth": {}}}
+	}
+	return item
+}
+
+func (op Operation) content(schema Schema) Schema {
+	content := Schema{echo.MIMEApplicationJSON: Schema{"schema": schema}}
+	for _, mediaType := range op.MediaTypes {
+		content[mediaType] = Schema{"schema": schema}
+	}
+	return content
+}
+
+func (p Param) schema() Schema {
+	schema := Schema{"type": "string"}
+	if p.Type != "" {
+		schema["type"] = p.Type
+	}
+	if p.Format != "" {
+		schema["format"] = p.Format
+	}
+	if len(p.Enum) > 0 {
+		schema["enum"] = p.Enum
+	}
+	if p.Description != "" {
+		schema["description"] = p.Description
+	}
+	return schema
+}
+
+// operationID turns GET /books/:id into getBooksById
+func operationID(route *echo.Route) string {
+	id := strings.ToLower(route.Method)
+	for _, part := range strings.Split(route.Path, "/") {
+		if part == "" {
+			continue
+		}
+		if strings.HasPrefix(part, ":") {
+			id += "By"
+			part = part[1:]
+		}
+		part = strings.NewReplacer(".", "", "-", "", "_", "", "*", "").Replace(part)
+		if part != "" {
+			id += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return id
+}
@@ -0,0 +1,92 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+)
+
+type generator struct {
+	schemas map[string]Schema
+}
+
+// schema describes t in JSON Schema 2020-12, registering named structs
+// as components and referencing them
+func (g *generator) schema(t reflect.Type) Schema {
+	switch t {
+	case timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case deletedAtType:
+		return Schema{"type": []string{"string", "null"}, "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := g.schema(t.Elem())
+		if kind, found := schema["type"].(string); found {
+			schema["type"] = []string{kind, "null"}
+			return schema
+		}
+		return Schema{"oneOf": []Schema{schema, {"type": "null"}}}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		// generic envelopes are inlined as their names are not readable
+		name := t.Name()
+		if name == "" || strings.Contains(name, "[") {
+			return g.object(t)
+		}
+		// reserve the name first so recursive types terminate
+		if _, found := g.schemas[name]; !found {
+			g.schemas[name] = Schema{}
+			g.schemas[name] = g.object(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + name}
+	}
+	return Schema{}
+}
+
+func (g *generator) object(t reflect.Type) Schema {
+	properties := Schema{}
+	g.fields(t, properties)
+	return Schema{"type": "object", "properties": properties}
+}
+
+func (g *generator) fields(t reflect.Type, properties Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// embedded structs such as gorm.Model are flattened like encoding/json does
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			g.fields(field.Type, properties)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schema(field.Type)
+	}
+}
@@ -0,0 +1,43 @@
+package openapi
+
+import (
+	"embed"
+	"net/http"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+// the swagger ui needs its own scripts and inline styles
+const uiPolicy = "default-src 'self'; img-src 'self' data:; style-src 'self' 'unsafe-inline'"
+
+// Spec serves the document built on the first request, once every route
+// has been registered
+func Spec(build func() *Document) echo.HandlerFunc {
+	var (
+		once sync.Once
+		doc  *Document
+	)
+	return func(ctx echo.Context) error {
+		once.Do(func() { doc = build() })
+		return ctx.JSON(http.StatusOK, doc)
+	}
+}
+
+func UI() echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		ctx.Response().Header().Set(echo.HeaderContentSecurityPolicy, uiPolicy)
+		switch file := ctx.Param("*"); file {
+		case "", "index.html":
+			return echo.StaticFileHandler("ui/index.html", uiFiles)(ctx)
+		case "initializer.js":
+			return echo.StaticFileHandler("ui/initializer.js", uiFiles)(ctx)
+		default:
+			return echo.StaticFileHandler(file, swaggerFiles.FS)(ctx)
+		}
+	}
+}
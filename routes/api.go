@@ -1,45 +1,79 @@
 package routes
 
 import (
+	"strings"
+
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
+	mw "github.com/labstack/echo/v4/middleware"
 	"github.com/rizghz/api/configs"
 	. "github.com/rizghz/api/controllers"
+	"github.com/rizghz/api/metrics"
+	"github.com/rizghz/api/routes/middleware"
 )
 
-var (
-	key, _ = configs.NewJwtEnv()
-)
+// VersionRoute groups the routes of an API version. A token is optional but
+// attributes the request to its user for the rate limits and idempotency keys.
+func VersionRoute(e *echo.Echo, version string, api *configs.APIConfig, jwt *configs.JwtConfig) *echo.Group {
+	return e.Group("/"+version, middleware.Negotiate(), middleware.Version(version, api), middleware.OptionalJWT(jwt))
+}
 
-func UserRoute(e *echo.Echo, c IUserController) {
-	users, _ := e.Group("/users"), echojwt.JWT([]byte(key["SECRET_KEY"].(string)))
-	users.GET("/login", c.Login())
+func UserRoute(g *echo.Group, c IUserController, conf *configs.JwtConfig, limiter *middleware.RateLimiter, limits *configs.BodyLimitConfig, idempotent echo.MiddlewareFunc) {
+	users, _ := g.Group("/users", mw.BodyLimit(limits.Users), limiter.Default()), echojwt.JWT([]byte(conf.Secret))
+	users.GET("/login", c.Login(), limiter.Auth())
 	// users.GET("", c.Index(), jwt)
 	users.GET("", c.Index())
 	// users.GET("/:id", c.Observe(), jwt)
 	users.GET("/:id", c.Observe())
-	users.POST("", c.Store())
+	users.POST("", c.Store(), limiter.Auth(), idempotent)
 	// users.PUT("/:id", c.Edit(), jwt)
 	users.PUT("/:id", c.Edit())
 	// users.DELETE("/:id", c.Destroy(), jwt)
 	users.DELETE("/:id", c.Destroy())
 }
 
-func BookRoute(e *echo.Echo, c IBookController) {
-	books := e.Group("/books")
+func BookRoute(g *echo.Group, c IBookController, limiter *middleware.RateLimiter, limits *configs.BodyLimitConfig, idempotent echo.MiddlewareFunc) {
+	public := middleware.CacheControl()
+	// imports carry whole files and get their own, larger limit
+	books := g.Group("/books", mw.BodyLimitWithConfig(mw.BodyLimitConfig{
+		Skipper: func(ctx echo.Context) bool { return strings.HasSuffix(ctx.Path(), "/books/import") },
+		Limit:   limits.Books,
+	}), limiter.Default())
 	// books.Use(echojwt.JWT(echojwt.JWT([]byte(key["SECRET_KEY"].(string)))))
-	books.GET("", c.Index())
-	books.GET("/:id", c.Observe())
-	books.POST("", c.Store())
+	books.GET("", c.Index(), public)
+	books.GET("/export", c.Export())
+	books.POST("/import", c.Import(), mw.BodyLimit(limits.Import), idempotent)
+	books.GET("/:id", c.Observe(), public)
+	books.POST("", c.Store(), idempotent)
 	books.PUT("/:id", c.Edit())
 	books.DELETE("/:id", c.Destroy())
 }
 
-func BlogRoute(e *echo.Echo, c IBlogController) {
-	blogs := e.Group("/blogs")
-	blogs.GET("", c.Index())
-	blogs.GET("/:id", c.Observe())
-	blogs.POST("", c.Store())
+func BlogRoute(g *echo.Group, c IBlogController, limiter *middleware.RateLimiter, limits *configs.BodyLimitConfig, idempotent echo.MiddlewareFunc) {
+	public := middleware.CacheControl()
+	blogs := g.Group("/blogs", mw.BodyLimit(limits.Blogs), limiter.Default())
+	blogs.GET("", c.Index(), public)
+	blogs.GET("/:id", c.Observe(), public)
+	blogs.POST("", c.Store(), idempotent)
 	blogs.PUT("/:id", c.Edit())
 	blogs.DELETE("/:id", c.Destroy())
 }
+
+func SearchRoute(g *echo.Group, c ISearchController, limiter *middleware.RateLimiter) {
+	g.GET("/search", c.Search(), limiter.Default())
+}
+
+func GraphQLRoute(e *echo.Echo, c IGraphQLController, conf *configs.JwtConfig, limiter *middleware.RateLimiter, limits *configs.BodyLimitConfig) {
+	graphql := e.Group("/graphql", mw.BodyLimit(limits.GraphQL), middleware.OptionalJWT(conf), limiter.Default(), limiter.Logins())
+	graphql.GET("", c.Query())
+	graphql.POST("", c.Query())
+}
+
+func HealthRoute(e *echo.Echo, c IHealthController) {
+	e.GET("/healthz", c.Liveness())
+	e.GET("/readyz", c.Readiness())
+}
+
+func MetricsRoute(e *echo.Echo) {
+	e.GET("/metrics", echo.WrapHandler(metrics.Handler()))
+}
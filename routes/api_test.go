@@ -0,0 +1,735 @@
+package routes
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/configs"
+	"github.com/rizghz/api/helpers"
+	"github.com/rizghz/api/models"
+	"github.com/rizghz/api/routes/middleware"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestVersioning(t *testing.T) {
+	e := newTestEcho()
+	e.Pre(middleware.Versioning(testAPI, "/users", "/books", "/blogs"))
+
+	t.Run("Valid Versioning Default", func(t *testing.T) {
+		req, rec, res := httptest.NewRequest(http.MethodGet, "/books/satu", nil), httptest.NewRecorder(), map[string]any{}
+		e.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "invalid book id", res["message"])
+		assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+		assert.Equal(t, `</v2/books/satu>; rel="successor-version"`, rec.Header().Get("Link"))
+	})
+
+	t.Run("Valid Versioning Accept", func(t *testing.T) {
+		req, rec, res := httptest.NewRequest(http.MethodGet, "/books/satu", nil), httptest.NewRecorder(), map[string]any{}
+		req.Header.Set(echo.HeaderAccept, "application/vnd.rizghz.v2+json")
+		e.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, map[string]any{"code": float64(400), "message": "invalid book id"}, res["error"])
+		assert.Empty(t, rec.Header().Get("Deprecation"))
+	})
+
+	t.Run("Valid Versioning Path", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodGet, "/v2/blogs/satu", nil), httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, "v2", rec.Header().Get("API-Version"))
+		assert.Contains(t, rec.Body.String(), `"error"`)
+	})
+
+	t.Run("Invalid Versioning (unknown version)", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodGet, "/books", nil), httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAccept, "application/json; version=9")
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	})
+}
+
+func TestNegotiation(t *testing.T) {
+	e := echo.New()
+	e.Binder = &middleware.Binder{}
+	g := e.Group("/v1", middleware.Negotiate())
+	g.GET("/books", func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK, helpers.FormatResponse("success", []models.Book{
+			{ISBN: "978", Title: "Satu"}, {ISBN: "979", Title: "Dua, Tiga"},
+		}))
+	})
+	g.GET("/books/:id", func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK, helpers.FormatResponse("success", models.Book{Title: "Satu"}))
+	})
+	g.GET("/books/export", func(ctx echo.Context) error {
+		ctx.Response().Header().Set(echo.HeaderContentType, "application/x-ndjson")
+		return ctx.String(http.StatusOK, `{"title":"Satu"}`+"\n")
+	})
+	g.POST("/books", func(ctx echo.Context) error {
+		book := models.Book{}
+		if err := ctx.Bind(&book); err != nil {
+			return ctx.JSON(http.StatusBadRequest, helpers.FormatResponse("invalid book data", nil))
+		}
+		return ctx.JSON(http.StatusCreated, helpers.FormatResponse("success", book))
+	})
+
+	t.Run("Valid Negotiation XML", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodGet, "/v1/books/1", nil), httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAccept, "application/xml")
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/xml; charset=UTF-8", rec.Header().Get(echo.HeaderContentType))
+		assert.Contains(t, rec.Body.String(), "<response><data><ID>0</ID>")
+		assert.Contains(t, rec.Body.String(), "<message>success</message></response>")
+		assert.Contains(t, rec.Body.String(), "<title>Satu</title>")
+	})
+
+	t.Run("Valid Negotiation MessagePack", func(t *testing.T) {
+		req, rec, res := httptest.NewRequest(http.MethodGet, "/v1/books/1", nil), httptest.NewRecorder(), map[string]any{}
+		req.Header.Set(echo.HeaderAccept, "application/x-msgpack, application/json;q=0.5")
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, "application/msgpack", rec.Header().Get(echo.HeaderContentType))
+		if assert.NoError(t, msgpack.Unmarshal(rec.Body.Bytes(), &res)) {
+			assert.Equal(t, "Satu", res["data"].(map[string]any)["title"])
+		}
+	})
+
+	t.Run("Valid Negotiation CSV", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodGet, "/v1/books", nil), httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAccept, "text/csv")
+		e.ServeHTTP(rec, req)
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		if assert.NoError(t, err) && assert.Len(t, records, 3) {
+			assert.Equal(t, []string{"ID", "CreatedAt", "UpdatedAt", "DeletedAt", "isbn", "title", "author", "publisher"}, records[0])
+			assert.Equal(t, "Dua, Tiga", records[2][5])
+		}
+	})
+
+	t.Run("Valid Negotiation Request Body", func(t *testing.T) {
+		for contentType, body := range map[string]string{
+			"application/xml": "<book><isbn>978</isbn><title>Satu</title></book>",
+			"text/csv":        "isbn,title\n978,Satu\n",
+		} {
+			req, rec, res := httptest.NewRequest(http.MethodPost, "/v1/books", strings.NewReader(body)), httptest.NewRecorder(), map[string]any{}
+			req.Header.Set(echo.HeaderContentType, contentType)
+			e.ServeHTTP(rec, req)
+			json.Unmarshal(rec.Body.Bytes(), &res)
+			if assert.Equal(t, http.StatusCreated, rec.Code, contentType) {
+				assert.Equal(t, "Satu", res["data"].(map[string]any)["title"], contentType)
+			}
+		}
+
+		packed, _ := msgpack.Marshal(map[string]any{"isbn": "978", "title": "Satu"})
+		req, rec, res := httptest.NewRequest(http.MethodPost, "/v1/books", bytes.NewReader(packed)), httptest.NewRecorder(), map[string]any{}
+		req.Header.Set(echo.HeaderContentType, "application/msgpack")
+		e.ServeHTTP(rec, req)
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		if assert.Equal(t, http.StatusCreated, rec.Code) {
+			assert.Equal(t, "978", res["data"].(map[string]any)["isbn"])
+		}
+
+		packed, _ = msgpack.Marshal(map[any]any{"title": "Satu", 1: "satu"})
+		req, rec = httptest.NewRequest(http.MethodPost, "/v1/books", bytes.NewReader(packed)), httptest.NewRecorder()
+		req.Header.Set(echo.HeaderContentType, "application/msgpack")
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code, "keys that are not strings still bind")
+	})
+
+	t.Run("Valid Negotiation Export", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodGet, "/v1/books/export", nil), httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAccept, "application/x-ndjson")
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get(echo.HeaderContentType))
+	})
+
+	t.Run("Invalid Negotiation (not acceptable)", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodGet, "/v1/books", nil), httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAccept, "text/html")
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	})
+
+	t.Run("Invalid Negotiation (csv of a single book)", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodGet, "/v1/books/1", nil), httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAccept, "text/csv")
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	})
+
+	t.Run("Invalid Negotiation (unsupported media type)", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodPost, "/v1/books", strings.NewReader("title: Satu")), httptest.NewRecorder()
+		req.Header.Set(echo.HeaderContentType, "application/yaml")
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	})
+}
+
+func TestCacheControl(t *testing.T) {
+	e, title := echo.New(), "Satu"
+	e.GET("/books/:id", func(ctx echo.Context) error {
+		if ctx.Param("id") != "1" {
+			return ctx.JSON(http.StatusNotFound, helpers.FormatResponse("book not found", nil))
+		}
+		return ctx.JSON(http.StatusOK, helpers.FormatResponse("success", models.Book{Title: title}))
+	}, middleware.CacheControl())
+	get := func(etag string) *httptest.ResponseRecorder {
+		req, rec := httptest.NewRequest(http.MethodGet, "/books/1", nil), httptest.NewRecorder()
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Valid Cache Control", func(t *testing.T) {
+		rec := get("")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "private, no-cache", rec.Header().Get(echo.HeaderCacheControl))
+		assert.Regexp(t, `^W/"[0-9a-f]{32}"$`, rec.Header().Get("ETag"))
+		assert.Contains(t, rec.Body.String(), `"title":"Satu"`)
+	})
+
+	t.Run("Valid Cache Control Not Modified", func(t *testing.T) {
+		etag := get("").Header().Get("ETag")
+		rec := get(`"other", ` + strings.TrimPrefix(etag, "W/"))
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+		assert.Equal(t, etag, rec.Header().Get("ETag"))
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("Valid Cache Control Modified", func(t *testing.T) {
+		etag := get("").Header().Get("ETag")
+		title = "Dua"
+		defer func() { title = "Satu" }()
+		rec := get(etag)
+		assert.Equal(t, http.StatusOK, rec.Code, "a write changes the etag")
+		assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+		assert.Contains(t, rec.Body.String(), `"title":"Dua"`)
+	})
+
+	t.Run("Invalid Cache Control (not found)", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodGet, "/books/2", nil), httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, rec.Header().Get(echo.HeaderCacheControl))
+		assert.Empty(t, rec.Header().Get("ETag"))
+	})
+}
+
+func TestIdempotency(t *testing.T) {
+	e, created, release := echo.New(), 0, make(chan struct{})
+	e.Binder = &middleware.Binder{}
+	limiter := middleware.NewRateLimiter(&configs.RateLimitConfig{APIKeyHeader: "X-API-Key", APIKeys: []string{"issued"}},
+		middleware.NewMemoryStore())
+	idempotent := middleware.Idempotency(&configs.IdempotencyConfig{Enabled: true, TTL: time.Hour}, middleware.NewIdempotencyMemoryStore(), limiter)
+	e.POST("/books", func(ctx echo.Context) error {
+		book := models.Book{}
+		if err := ctx.Bind(&book); err != nil {
+			return ctx.JSON(http.StatusBadRequest, helpers.FormatResponse("invalid book data", nil))
+		}
+		created++
+		book.ID = uint(created)
+		if book.Title == "slow" {
+			<-release
+		}
+		if book.Title == "broken" && created == 1 {
+			return ctx.JSON(http.StatusInternalServerError, helpers.FormatResponse("server error", nil))
+		}
+		ctx.Response().Header().Set(echo.HeaderLocation, "/books/"+strconv.Itoa(created))
+		return ctx.JSON(http.StatusCreated, helpers.FormatResponse("success", book))
+	}, middleware.OptionalJWT(testJwt), idempotent)
+	// headers holds pairs of header names and values
+	post := func(key string, body string, remote string, headers ...string) *httptest.ResponseRecorder {
+		req, rec := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(body)), httptest.NewRecorder()
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if remote != "" {
+			req.RemoteAddr = remote
+		}
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		if key != "" {
+			req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		}
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Valid Idempotency Replay", func(t *testing.T) {
+		created = 0
+		first, retry := post("satu", `{"title":"Satu"}`, ""), post("satu", `{"title":"Satu"}`, "")
+		assert.Equal(t, 1, created)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, "/books/1", retry.Header().Get(echo.HeaderLocation))
+		assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+		assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+	})
+
+	t.Run("Valid Idempotency Without Key", func(t *testing.T) {
+		created = 0
+		post("", `{"title":"Satu"}`, "")
+		post("", `{"title":"Satu"}`, "")
+		post("dua", `{"title":"Satu"}`, "")
+		assert.Equal(t, 3, created)
+	})
+
+	t.Run("Valid Idempotency Released", func(t *testing.T) {
+		created = 0
+		assert.Equal(t, http.StatusInternalServerError, post("tiga", `{"title":"broken"}`, "").Code)
+		assert.Equal(t, http.StatusCreated, post("tiga", `{"title":"broken"}`, "").Code)
+		assert.Equal(t, 2, created, "failed requests can be retried")
+	})
+
+	t.Run("Invalid Idempotency (different body)", func(t *testing.T) {
+		created = 0
+		post("empat", `{"title":"Satu"}`, "")
+		rec, res := post("empat", `{"title":"Dua"}`, ""), map[string]any{}
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Equal(t, "idempotency key reused with a different request", res["message"])
+		assert.Equal(t, 1, created)
+	})
+
+	t.Run("Invalid Idempotency (in progress)", func(t *testing.T) {
+		done := make(chan *httptest.ResponseRecorder)
+		go func() { done <- post("lima", `{"title":"slow"}`, "") }()
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, http.StatusConflict, post("lima", `{"title":"slow"}`, "").Code)
+		close(release)
+		assert.Equal(t, http.StatusCreated, (<-done).Code)
+	})
+
+	t.Run("Valid Idempotency Concurrent Retries", func(t *testing.T) {
+		created, release = 0, make(chan struct{})
+		first := make(chan *httptest.ResponseRecorder)
+		go func() { first <- post("enam", `{"title":"slow"}`, "") }()
+		time.Sleep(20 * time.Millisecond)
+		var wg sync.WaitGroup
+		replayed := make(chan string, 50)
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// retry until the first request finishes, as a client would
+				for n := 0; n < 10; n++ {
+					rec := post("enam", `{"title":"slow"}`, "")
+					if rec.Code != http.StatusConflict {
+						replayed <- rec.Body.String()
+						return
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+			}()
+		}
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		original := (<-first).Body.String()
+		wg.Wait()
+		close(replayed)
+		assert.Equal(t, 1, created)
+		for body := range replayed {
+			assert.Equal(t, original, body)
+		}
+	})
+
+	t.Run("Valid Idempotency Scoped To The Client", func(t *testing.T) {
+		created = 0
+		first := post("tujuh", `{"title":"Satu"}`, "192.0.2.1:1234")
+		other := post("tujuh", `{"title":"Satu"}`, "192.0.2.2:1234")
+		assert.Equal(t, 2, created, "another client reusing a key gets its own response")
+		assert.Empty(t, other.Header().Get("Idempotent-Replayed"))
+		assert.NotEqual(t, first.Body.String(), other.Body.String())
+	})
+
+	t.Run("Valid Idempotency Retried From Another Address", func(t *testing.T) {
+		created = 0
+		token, _ := middleware.CreateToken(testJwt, 7)
+		auth := "Bearer " + token
+		first := post("delapan", `{"title":"Satu"}`, "192.0.2.1:1234", echo.HeaderAuthorization, auth)
+		retry := post("delapan", `{"title":"Satu"}`, "198.51.100.1:1234", echo.HeaderAuthorization, auth)
+		assert.Equal(t, 1, created, "a user's retry replays on any network")
+		assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+
+		post("sembilan", `{"title":"Satu"}`, "192.0.2.1:1234", "X-API-Key", "issued")
+		retry = post("sembilan", `{"title":"Satu"}`, "198.51.100.1:1234", "X-API-Key", "issued")
+		assert.Equal(t, 2, created, "so does the retry of a client with an issued api key")
+		assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	})
+
+	t.Run("Invalid Idempotency (key too long)", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, post(strings.Repeat("k", 256), `{"title":"Satu"}`, "").Code)
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	e := echo.New()
+	e.Use(middleware.Metrics())
+	e.GET("/metered/:id", func(ctx echo.Context) error {
+		if ctx.Param("id") != "1" {
+			return echo.ErrNotFound
+		}
+		return ctx.JSON(http.StatusOK, helpers.FormatResponse("success", nil))
+	})
+	MetricsRoute(e)
+	for _, path := range []string{"/metered/1", "/metered/1", "/metered/2"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	t.Run("Valid Metrics Scrape", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodGet, "/metrics", nil), httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, `http_requests_total{method="GET",route="/metered/:id",status="200"} 2`)
+		assert.Contains(t, body, `http_requests_total{method="GET",route="/metered/:id",status="404"} 1`)
+		assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/metered/:id",status="200"} 2`)
+		assert.Contains(t, body, `http_request_duration_seconds_bucket{method="GET",route="/metered/:id",status="404",le="+Inf"} 1`)
+		assert.Contains(t, body, "http_requests_in_flight 1", "only the scrape itself is in flight")
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	e := echo.New()
+	e.Use(middleware.RequestID())
+	e.GET("/books", func(ctx echo.Context) error {
+		return ctx.String(http.StatusOK, helpers.RequestID(ctx.Request().Context()))
+	})
+
+	t.Run("Valid Request ID Reused", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodGet, "/books", nil), httptest.NewRecorder()
+		req.Header.Set(echo.HeaderXRequestID, "client-id-1")
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, "client-id-1", rec.Header().Get(echo.HeaderXRequestID))
+		assert.Equal(t, "client-id-1", rec.Body.String())
+	})
+
+	t.Run("Valid Request ID Generated", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodGet, "/books", nil), httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Len(t, rec.Header().Get(echo.HeaderXRequestID), 32)
+		assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), rec.Body.String())
+	})
+
+	t.Run("Invalid Request ID (replaced)", func(t *testing.T) {
+		for _, id := range []string{"with space", strings.Repeat("x", 129)} {
+			req, rec := httptest.NewRequest(http.MethodGet, "/books", nil), httptest.NewRecorder()
+			req.Header.Set(echo.HeaderXRequestID, id)
+			e.ServeHTTP(rec, req)
+			assert.NotEqual(t, id, rec.Header().Get(echo.HeaderXRequestID))
+			assert.Len(t, rec.Header().Get(echo.HeaderXRequestID), 32)
+		}
+	})
+}
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	logrus.SetOutput(&out)
+	logrus.SetFormatter(&logrus.JSONFormatter{})
+	t.Cleanup(func() {
+		logrus.SetOutput(os.Stderr)
+		logrus.SetFormatter(&logrus.TextFormatter{})
+	})
+	// the order of main, where the metrics render the errors first
+	e := echo.New()
+	e.Use(middleware.RequestID(), middleware.Logger(), middleware.Metrics())
+	e.GET("/books/:id", func(ctx echo.Context) error {
+		switch ctx.Param("id") {
+		case "1":
+			return ctx.JSON(http.StatusOK, helpers.FormatResponse("success", nil))
+		case "2":
+			return echo.NewHTTPError(http.StatusNotFound, "book not found")
+		}
+		return errors.New("[err]: database is gone")
+	})
+	serve := func(path string) map[string]any {
+		out.Reset()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderXRequestID, "logged-1")
+		e.ServeHTTP(httptest.NewRecorder(), req)
+		entry := map[string]any{}
+		json.Unmarshal(out.Bytes(), &entry)
+		return entry
+	}
+
+	t.Run("Valid Logger Served", func(t *testing.T) {
+		entry := serve("/books/1")
+		assert.Equal(t, "info", entry["level"])
+		assert.Equal(t, "request served", entry["msg"])
+		assert.Equal(t, "/books/:id", entry["route"])
+		assert.Equal(t, float64(http.StatusOK), entry["status"])
+		assert.Equal(t, "logged-1", entry["request_id"])
+		assert.NotContains(t, entry, "error")
+	})
+
+	t.Run("Invalid Logger (rejected)", func(t *testing.T) {
+		entry := serve("/books/2")
+		assert.Equal(t, "warning", entry["level"])
+		assert.Equal(t, float64(http.StatusNotFound), entry["status"])
+		assert.Contains(t, entry["error"], "book not found")
+	})
+
+	t.Run("Invalid Logger (failed)", func(t *testing.T) {
+		entry := serve("/books/3")
+		assert.Equal(t, "error", entry["level"])
+		assert.Equal(t, "request failed", entry["msg"])
+		assert.Equal(t, float64(http.StatusInternalServerError), entry["status"])
+		assert.Equal(t, "[err]: database is gone", entry["error"])
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	e := echo.New()
+	e.Use(middleware.Timeout(time.Second, time.Hour))
+	deadline := func(ctx echo.Context) error {
+		at, _ := ctx.Request().Context().Deadline()
+		return ctx.String(http.StatusOK, time.Until(at).Round(time.Minute).String())
+	}
+	e.GET("/v1/books", deadline)
+	e.GET("/v1/books/export", deadline)
+
+	t.Run("Valid Timeout Request", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodGet, "/v1/books", nil), httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, "0s", rec.Body.String())
+	})
+
+	t.Run("Valid Timeout Export", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodGet, "/v1/books/export", nil), httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, "1h0m0s", rec.Body.String())
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	newEcho := func(read configs.Rate, proxies ...*net.IPNet) *echo.Echo {
+		e := echo.New()
+		e.IPExtractor = middleware.IPExtractor(proxies)
+		limiter := middleware.NewRateLimiter(&configs.RateLimitConfig{
+			Enabled: true, Read: read, Write: "1/1m", Auth: "1/1m",
+			APIKeyHeader: "X-API-Key", APIKeys: []string{"issued"},
+		}, middleware.NewMemoryStore())
+		e.GET("/books", func(ctx echo.Context) error {
+			return ctx.NoContent(http.StatusOK)
+		}, limiter.Default())
+		return e
+	}
+	get := func(e *echo.Echo, ip string, headers ...string) *httptest.ResponseRecorder {
+		req, rec := httptest.NewRequest(http.MethodGet, "/books", nil), httptest.NewRecorder()
+		req.RemoteAddr = ip + ":4321"
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Valid Rate Limit Headers", func(t *testing.T) {
+		rec := get(newEcho("60/1m"), "192.0.2.1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "60;w=60", rec.Header().Get("RateLimit-Policy"))
+		assert.Equal(t, "60", rec.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "59", rec.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Reset"))
+	})
+
+	t.Run("Valid Rate Limit Refill", func(t *testing.T) {
+		e := newEcho("2/200ms")
+		get(e, "192.0.2.1")
+		get(e, "192.0.2.1")
+		assert.Equal(t, http.StatusTooManyRequests, get(e, "192.0.2.1").Code)
+		time.Sleep(120 * time.Millisecond)
+		assert.Equal(t, http.StatusOK, get(e, "192.0.2.1").Code, "a token refills every 100ms")
+	})
+
+	t.Run("Valid Rate Limit Issued Key", func(t *testing.T) {
+		e := newEcho("2/200ms")
+		get(e, "192.0.2.1")
+		get(e, "192.0.2.1")
+		assert.Equal(t, http.StatusOK, get(e, "192.0.2.1", "X-API-Key", "issued").Code, "issued keys have their own bucket")
+	})
+
+	t.Run("Valid Rate Limit User", func(t *testing.T) {
+		e := echo.New()
+		limiter := middleware.NewRateLimiter(&configs.RateLimitConfig{Enabled: true, Read: "2/1m", Write: "2/1m", Auth: "1/1m"},
+			middleware.NewMemoryStore())
+		VersionRoute(e, "v1", testAPI, testJwt).GET("/books", func(ctx echo.Context) error {
+			return ctx.NoContent(http.StatusOK)
+		}, limiter.Default())
+		token, _ := middleware.CreateToken(testJwt, 7)
+		send := func(ip string, token string) int {
+			req, rec := httptest.NewRequest(http.MethodGet, "/v1/books", nil), httptest.NewRecorder()
+			req.RemoteAddr = ip + ":4321"
+			if token != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+			}
+			e.ServeHTTP(rec, req)
+			return rec.Code
+		}
+		send("192.0.2.1", token)
+		send("192.0.2.2", token)
+		assert.Equal(t, http.StatusTooManyRequests, send("192.0.2.3", token), "the user has one bucket on every address")
+		assert.Equal(t, http.StatusOK, send("192.0.2.1", ""), "anonymous requests keep the bucket of their address")
+	})
+
+	t.Run("Valid Rate Limit Trusted Proxy", func(t *testing.T) {
+		_, proxy, _ := net.ParseCIDR("10.0.0.0/8")
+		e := newEcho("2/200ms", proxy)
+		get(e, "10.0.0.5", echo.HeaderXForwardedFor, "198.51.100.1")
+		get(e, "10.0.0.5", echo.HeaderXForwardedFor, "198.51.100.1")
+		assert.Equal(t, http.StatusTooManyRequests, get(e, "10.0.0.6", echo.HeaderXForwardedFor, "198.51.100.1").Code)
+		assert.Equal(t, http.StatusOK, get(e, "10.0.0.5", echo.HeaderXForwardedFor, "198.51.100.2").Code)
+	})
+
+	t.Run("Invalid Rate Limit (burst)", func(t *testing.T) {
+		e := newEcho("2/200ms")
+		get(e, "192.0.2.1")
+		get(e, "192.0.2.1")
+		rec, res := get(e, "192.0.2.1"), map[string]any{}
+		json.Unmarshal(rec.Body.Bytes(), &res)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "too many requests", res["message"])
+		assert.Equal(t, "1", rec.Header().Get(echo.HeaderRetryAfter))
+		assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, http.StatusOK, get(e, "192.0.2.2").Code, "other clients keep their bucket")
+	})
+
+	t.Run("Invalid Rate Limit (unknown key)", func(t *testing.T) {
+		e := newEcho("2/200ms")
+		get(e, "192.0.2.1", "X-API-Key", "random-1")
+		get(e, "192.0.2.1", "X-API-Key", "random-2")
+		assert.Equal(t, http.StatusTooManyRequests, get(e, "192.0.2.1", "X-API-Key", "random-3").Code)
+	})
+
+	t.Run("Invalid Rate Limit (spoofed forwarded for)", func(t *testing.T) {
+		e := newEcho("2/200ms")
+		get(e, "192.0.2.1", echo.HeaderXForwardedFor, "198.51.100.1")
+		get(e, "192.0.2.1", echo.HeaderXForwardedFor, "198.51.100.2")
+		assert.Equal(t, http.StatusTooManyRequests, get(e, "192.0.2.1", echo.HeaderXForwardedFor, "198.51.100.3").Code)
+	})
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat(`{"title":"Satu"}`, 200)
+	e := echo.New()
+	e.Use(middleware.Compress([]string{"br", "gzip"}, 1024))
+	e.Match([]string{http.MethodGet, http.MethodHead}, "/large", func(ctx echo.Context) error {
+		ctx.Response().Header().Set(echo.HeaderContentLength, strconv.Itoa(len(large)))
+		return ctx.Blob(http.StatusOK, echo.MIMEApplicationJSON, []byte(large))
+	})
+	e.GET("/small", func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK, helpers.FormatResponse("success", nil))
+	})
+	e.GET("/encoded", func(ctx echo.Context) error {
+		ctx.Response().Header().Set(echo.HeaderContentEncoding, "gzip")
+		return ctx.Blob(http.StatusOK, echo.MIMEApplicationJSON, []byte(large))
+	})
+	e.GET("/image", func(ctx echo.Context) error {
+		return ctx.Blob(http.StatusOK, "image/png", []byte(large))
+	})
+	e.GET("/stream", func(ctx echo.Context) error {
+		res := ctx.Response()
+		res.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{"title":"Satu"}` + "\n"))
+		res.Flush()
+		res.Write([]byte(`{"title":"Dua"}` + "\n"))
+		return nil
+	})
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req, rec := httptest.NewRequest(http.MethodGet, path, nil), httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAcceptEncoding, acceptEncoding)
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	decode := func(rec *httptest.ResponseRecorder) string {
+		var reader io.Reader = rec.Body
+		switch rec.Header().Get(echo.HeaderContentEncoding) {
+		case "gzip":
+			reader, _ = gzip.NewReader(rec.Body)
+		case "br":
+			reader = brotli.NewReader(rec.Body)
+		}
+		body, _ := io.ReadAll(reader)
+		return string(body)
+	}
+
+	t.Run("Valid Compress Negotiation", func(t *testing.T) {
+		for acceptEncoding, encoding := range map[string]string{
+			"gzip":             "gzip",
+			"gzip, br":         "br",
+			"br;q=0.5, gzip":   "gzip",
+			"br;q=0, gzip":     "gzip",
+			"*":                "br",
+			"deflate":          "",
+			"identity, gzip;q": "gzip",
+		} {
+			rec := get("/large", acceptEncoding)
+			assert.Equal(t, encoding, rec.Header().Get(echo.HeaderContentEncoding), acceptEncoding)
+			assert.Equal(t, large, decode(rec), acceptEncoding)
+			assert.Equal(t, echo.HeaderAcceptEncoding, rec.Header().Get(echo.HeaderVary), acceptEncoding)
+		}
+	})
+
+	t.Run("Valid Compress Content Length", func(t *testing.T) {
+		rec := get("/large", "gzip")
+		assert.Empty(t, rec.Header().Get(echo.HeaderContentLength))
+		assert.Less(t, rec.Body.Len(), len(large))
+		rec = get("/large", "")
+		assert.Equal(t, strconv.Itoa(len(large)), rec.Header().Get(echo.HeaderContentLength))
+	})
+
+	t.Run("Valid Compress Flush", func(t *testing.T) {
+		rec := get("/stream", "gzip")
+		assert.True(t, rec.Flushed)
+		assert.Equal(t, "gzip", rec.Header().Get(echo.HeaderContentEncoding), "flushed streams are compressed")
+		assert.Equal(t, "{\"title\":\"Satu\"}\n{\"title\":\"Dua\"}\n", decode(rec))
+	})
+
+	t.Run("Invalid Compress (below min size)", func(t *testing.T) {
+		rec := get("/small", "gzip")
+		assert.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+		assert.Contains(t, rec.Body.String(), `"message":"success"`)
+		assert.Equal(t, echo.HeaderAcceptEncoding, rec.Header().Get(echo.HeaderVary))
+	})
+
+	t.Run("Invalid Compress (already encoded)", func(t *testing.T) {
+		rec := get("/encoded", "br")
+		assert.Equal(t, "gzip", rec.Header().Get(echo.HeaderContentEncoding))
+		assert.Equal(t, large, rec.Body.String())
+	})
+
+	t.Run("Invalid Compress (compressed format)", func(t *testing.T) {
+		rec := get("/image", "gzip")
+		assert.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+		assert.Equal(t, large, rec.Body.String())
+	})
+
+	t.Run("Invalid Compress (head)", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodHead, "/large", nil), httptest.NewRecorder()
+		req.Header.Set(echo.HeaderAcceptEncoding, "gzip")
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+	})
+}
@@ -0,0 +1,308 @@
+package routes
+
+import (
+	"sort"
+	"strings"
+
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/configs"
+	. "github.com/rizghz/api/controllers"
+	"github.com/rizghz/api/graph"
+	"github.com/rizghz/api/models"
+	"github.com/rizghz/api/openapi"
+	"github.com/rizghz/api/routes/middleware"
+)
+
+var tableTypes = []string{"text/csv", "application/x-ndjson",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"}
+
+// apiDocs documents the routes mounted under every api version
+var apiDocs = map[string]openapi.Operation{
+	"GET /users/login": {
+		Summary: "Log in and receive a JWT",
+		Tags:    []string{"users"},
+		Query: []openapi.Param{
+			{Name: "email", Required: true, Format: "email"},
+			{Name: "password", Required: true, Format: "password"},
+		},
+		Responses: responses(http.StatusOK, openapi.Data[models.User]{}, http.StatusBadRequest, http.StatusTooManyRequests),
+	},
+	"GET /users": {
+		Summary:   "List users",
+		Tags:      []string{"users"},
+		Query:     fields(models.UserFields),
+		Responses: responses(http.StatusOK, openapi.Data[[]models.User]{}, http.StatusTooManyRequests),
+	},
+	"GET /users/:id": {
+		Summary:   "Get a user",
+		Tags:      []string{"users"},
+		Query:     fields(models.UserFields, "blogs"),
+		Responses: responses(http.StatusOK, openapi.Data[*models.User]{}, http.StatusBadRequest, http.StatusTooManyRequests),
+	},
+	"POST /users": {
+		Summary:   "Register a user",
+		Tags:      []string{"users"},
+		Body:      models.User{},
+		Responses: responses(http.StatusCreated, openapi.Data[models.User]{}, writeErrors...),
+	},
+	"PUT /users/:id": {
+		Summary:   "Update a user",
+		Tags:      []string{"users"},
+		Body:      models.User{},
+		Responses: responses(http.StatusCreated, openapi.Data[models.User]{}, writeErrors...),
+	},
+	"DELETE /users/:id": {
+		Summary:   "Delete a user",
+		Tags:      []string{"users"},
+		Responses: responses(http.StatusNoContent, nil, writeErrors...),
+	},
+
+	"GET /books": {
+		Summary:   "List books",
+		Tags:      []string{"books"},
+		Query:     append(fields(models.BookFields), search...),
+		Responses: responses(http.StatusOK, openapi.Data[[]models.Book]{}, http.StatusBadRequest, http.StatusTooManyRequests),
+	},
+	"GET /books/export": {
+		Summary: "Export all books as a table",
+		Tags:    []string{"books"},
+		Query: []openapi.Param{
+			{Name: "format", Enum: []string{"csv", "ndjson", "xlsx"}, Description: "defaults to csv"},
+		},
+		Responses: responses(http.StatusOK, openapi.File{Types: tableTypes}, http.StatusBadRequest, http.StatusTooManyRequests),
+	},
+	"POST /books/import": {
+		Summary: "Import books from a table",
+		Tags:    []string{"books"},
+		Form: []openapi.Param{
+			{Name: "file", Format: "binary", Required: true},
+			{Name: "format", Enum: []string{"csv", "ndjson"}, Description: "defaults to the file extension"},
+			{Name: "mapping", Description: "JSON object mapping file columns to book fields"},
+			{Name: "dry_run", Type: "boolean", Description: "validate without saving"},
+			{Name: "report", Enum: []string{"csv"}, Description: "respond with a CSV of the failed rows"},
+		},
+		Responses: responses(http.StatusOK, openapi.Data[BookImportResult]{}, writeErrors...),
+	},
+	"GET /books/:id": {
+		Summary:   "Get a book",
+		Tags:      []string{"books"},
+		Query:     fields(models.BookFields),
+		Responses: responses(http.StatusOK, openapi.Data[*models.Book]{}, http.StatusBadRequest, http.StatusTooManyRequests),
+	},
+	"POST /books": {
+		Summary:   "Create a book",
+		Tags:      []string{"books"},
+		Body:      models.Book{},
+		Responses: responses(http.StatusCreated, openapi.Data[models.Book]{}, writeErrors...),
+	},
+	"PUT /books/:id": {
+		Summary:   "Update a book",
+		Tags:      []string{"books"},
+		Body:      models.Book{},
+		Responses: responses(http.StatusCreated, openapi.Data[models.Book]{}, writeErrors...),
+	},
+	"DELETE /books/:id": {
+		Summary:   "Delete a book",
+		Tags:      []string{"books"},
+		Responses: responses(http.StatusNoContent, nil, writeErrors...),
+	},
+
+	"GET /blogs": {
+		Summary:   "List blogs",
+		Tags:      []string{"blogs"},
+		Query:     append(fields(models.BlogFields), search...),
+		Responses: responses(http.StatusOK, openapi.Data[[]models.Blog]{}, http.StatusBadRequest, http.StatusTooManyRequests),
+	},
+	"GET /blogs/:id": {
+		Summary:   "Get a blog",
+		Tags:      []string{"blogs"},
+		Query:     fields(models.BlogFields, "author"),
+		Responses: responses(http.StatusOK, openapi.Data[*models.Blog]{}, http.StatusBadRequest, http.StatusTooManyRequests),
+	},
+	"POST /blogs": {
+		Summary:   "Create a blog",
+		Tags:      []string{"blogs"},
+		Body:      models.Blog{},
+		Responses: responses(http.StatusCreated, openapi.Data[models.Blog]{}, writeErrors...),
+	},
+	"PUT /blogs/:id": {
+		Summary:   "Update a blog",
+		Tags:      []string{"blogs"},
+		Body:      models.Blog{},
+		Responses: responses(http.StatusCreated, openapi.Data[models.Blog]{}, writeErrors...),
+	},
+	"DELETE /blogs/:id": {
+		Summary:   "Delete a blog",
+		Tags:      []string{"blogs"},
+		Responses: responses(http.StatusNoContent, nil, writeErrors...),
+	},
+
+	"GET /search": {
+		Summary: "Search books and blogs, with typo tolerance and facets when the index is enabled",
+		Tags:    []string{"search"},
+		Query: append(append([]openapi.Param{{Name: "q", Required: true}}, search[1:]...),
+			openapi.Param{Name: "type", Enum: []string{"books", "blogs"}, Description: "needs the search index"},
+			openapi.Param{Name: "author", Description: "needs the search index"},
+			openapi.Param{Name: "publisher", Description: "needs the search index"},
+			openapi.Param{Name: "year", Description: "needs the search index"},
+		),
+		Responses: responses(http.StatusOK, openapi.Data[models.SearchResult]{},
+			http.StatusBadRequest, http.StatusTooManyRequests, http.StatusInternalServerError),
+	},
+}
+
+// search documents the full-text parameters of the lists
+var search = []openapi.Param{
+	{Name: "q", Description: "full-text query, the list then answers with a page of ranked hits"},
+	{Name: "page", Type: "integer", Description: "defaults to 1"},
+	{Name: "per_page", Type: "integer", Description: "defaults to 20, at most 100"},
+}
+
+// opsDocs documents the unversioned operational routes
+var opsDocs = map[string]openapi.Operation{
+	"GET /healthz": {
+		Summary:   "Liveness probe",
+		Tags:      []string{"operations"},
+		Responses: responses(http.StatusOK, openapi.Data[HealthStatus]{}),
+	},
+	"GET /readyz": {
+		Summary: "Readiness probe",
+		Tags:    []string{"operations"},
+		Responses: map[int]any{
+			http.StatusOK:                 openapi.Data[HealthStatus]{},
+			http.StatusServiceUnavailable: openapi.Data[HealthStatus]{},
+		},
+	},
+	"GET /metrics": {
+		Summary:   "Prometheus metrics",
+		Tags:      []string{"operations"},
+		Responses: responses(http.StatusOK, openapi.File{Types: []string{"text/plain"}}),
+	},
+	"GET /openapi.json": {
+		Summary:   "This document",
+		Tags:      []string{"operations"},
+		Responses: responses(http.StatusOK, openapi.File{Types: []string{echo.MIMEApplicationJSON}}),
+	},
+	"GET /graphql": {
+		Summary: "Run a GraphQL query",
+		Tags:    []string{"graphql"},
+		Query: []openapi.Param{
+			{Name: "query", Required: true},
+			{Name: "operationName"},
+			{Name: "variables", Format: "json"},
+		},
+		Responses: map[int]any{
+			http.StatusOK:              graphql.Result{},
+			http.StatusBadRequest:      graphql.Result{},
+			http.StatusUnauthorized:    openapi.Error{},
+			http.StatusTooManyRequests: openapi.Error{},
+		},
+	},
+	"POST /graphql": {
+		Summary: "Run a GraphQL query or mutation",
+		Tags:    []string{"graphql"},
+		Body:    graph.Request{},
+		Responses: map[int]any{
+			http.StatusOK:                    graphql.Result{},
+			http.StatusBadRequest:            graphql.Result{},
+			http.StatusUnauthorized:          openapi.Error{},
+			http.StatusRequestEntityTooLarge: openapi.Error{},
+			http.StatusTooManyRequests:       openapi.Error{},
+		},
+	},
+	"GET /docs":   {Hidden: true},
+	"GET /docs/*": {Hidden: true},
+}
+
+// fields documents the ?fields= and ?include= parameters of a read
+func fields(fields models.Fields, includes ...string) []openapi.Param {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	params := []openapi.Param{{Name: "fields",
+		Description: "comma separated fields to return, among " + strings.Join(names, ", ")}}
+	if len(includes) > 0 {
+		params = append(params, openapi.Param{Name: "include",
+			Description: "comma separated relations to embed, among " + strings.Join(includes, ", ")})
+	}
+	return params
+}
+
+var writeErrors = []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge,
+	http.StatusTooManyRequests, http.StatusInternalServerError}
+
+var idempotencyKey = openapi.Param{Name: middleware.IdempotencyKeyHeader,
+	Description: "unique key of the request, retries with the same key and body replay the first response"}
+
+// idempotent documents the Idempotency-Key header of a POST or PATCH
+func idempotent(op openapi.Operation) openapi.Operation {
+	op.Header = append(append([]openapi.Param{}, op.Header...), idempotencyKey)
+	res := map[int]any{http.StatusConflict: openapi.Error{}, http.StatusUnprocessableEntity: openapi.Error{}}
+	for status, body := range op.Responses {
+		res[status] = body
+	}
+	op.Responses = res
+	return op
+}
+
+// responses pairs the success status and body with the error statuses,
+// which all share the error schema
+func responses(status int, body any, errors ...int) map[int]any {
+	res := map[int]any{status: body}
+	for _, code := range errors {
+		res[code] = openapi.Error{}
+	}
+	return res
+}
+
+// Docs documents every registered route, keyed by method and path
+func Docs(conf *configs.APIConfig) map[string]openapi.Operation {
+	docs := map[string]openapi.Operation{}
+	for key, op := range opsDocs {
+		docs[key] = op
+	}
+	for _, version := range conf.Versions {
+		for key, op := range apiDocs {
+			method, path, _ := strings.Cut(key, " ")
+			op.Deprecated = conf.Deprecated(version)
+			op.MediaTypes = []string{"application/xml", "application/msgpack"}
+			if method == http.MethodPost || method == http.MethodPatch {
+				op = idempotent(op)
+			}
+			if version != "v1" {
+				res := map[int]any{}
+				for status, body := range op.Responses {
+					res[status] = openapi.EnvelopeV2(body)
+				}
+				op.Responses = res
+			}
+			docs[openapi.Key(method, "/"+version+path)] = op
+		}
+	}
+	return docs
+}
+
+func DocsRoute(e *echo.Echo, limits *configs.RateLimitConfig, api *configs.APIConfig) {
+	info := openapi.Info{
+		Title:   "Library API",
+		Version: "1.0.0",
+		Description: "Users, books and blogs. v1 responses wrap their payload as {\"message\", \"data\"}, " +
+			"v2 responses as {\"data\"} or {\"error\"}. Unversioned paths follow the Accept header " +
+			"(application/vnd.rizghz.v2+json) or default to the configured version. Responses and request " +
+			"bodies may also be XML or MessagePack, and lists may be requested as text/csv.",
+	}
+	security := map[string]openapi.Schema{
+		"bearerAuth": {"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+		"apiKey":     {"type": "apiKey", "in": "header", "name": limits.APIKeyHeader},
+	}
+	e.GET("/openapi.json", openapi.Spec(func() *openapi.Document {
+		return openapi.Build(info, e.Routes(), Docs(api), security)
+	}))
+	e.GET("/docs", openapi.UI())
+	e.GET("/docs/*", openapi.UI())
+}
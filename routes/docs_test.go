@@ -0,0 +1,77 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/configs"
+	"github.com/rizghz/api/controllers"
+	"github.com/rizghz/api/openapi"
+	"github.com/rizghz/api/routes/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+var testJwt = &configs.JwtConfig{Secret: "rahasia", Expiry: time.Hour}
+
+var testAPI = &configs.APIConfig{Versions: []string{"v1", "v2"}, DefaultVersion: "v1", DeprecatedVersions: []string{"v1"}}
+
+func newTestEcho() *echo.Echo {
+	e := echo.New()
+	e.Binder = &middleware.Binder{}
+	limiter := middleware.NewRateLimiter(&configs.RateLimitConfig{}, middleware.NewMemoryStore())
+	idempotent := middleware.Idempotency(&configs.IdempotencyConfig{Enabled: true, TTL: time.Hour}, middleware.NewIdempotencyMemoryStore(), limiter)
+	limits := &configs.BodyLimitConfig{Users: "1M", Books: "1M", Blogs: "1M", Import: "1M", GraphQL: "1M"}
+	for _, version := range testAPI.Versions {
+		api := VersionRoute(e, version, testAPI, testJwt)
+		UserRoute(api, controllers.NewUserController(nil), testJwt, limiter, limits, idempotent)
+		BookRoute(api, controllers.NewBookController(nil), limiter, limits, idempotent)
+		BlogRoute(api, controllers.NewBlogController(nil), limiter, limits, idempotent)
+		SearchRoute(api, controllers.NewSearchController(nil, nil, nil), limiter)
+	}
+	GraphQLRoute(e, controllers.NewGraphQLController(nil), testJwt, limiter, limits)
+	HealthRoute(e, controllers.NewHealthController(0))
+	MetricsRoute(e)
+	DocsRoute(e, &configs.RateLimitConfig{APIKeyHeader: "X-API-Key"}, testAPI)
+	return e
+}
+
+func TestDocs(t *testing.T) {
+	e := newTestEcho()
+
+	t.Run("Valid Docs Coverage", func(t *testing.T) {
+		assert.Empty(t, openapi.Undocumented(e.Routes(), Docs(testAPI)), "routes missing from routes.Docs")
+	})
+
+	t.Run("Valid Docs Document", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodGet, "/openapi.json", nil), httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		doc := openapi.Document{}
+		if assert.Equal(t, http.StatusOK, rec.Code) && assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc)) {
+			assert.Equal(t, "3.1.0", doc.OpenAPI)
+			assert.Contains(t, doc.Paths, "/v1/books/{id}")
+			assert.True(t, doc.Paths["/v1/books/{id}"]["get"].Deprecated)
+			assert.False(t, doc.Paths["/v2/books/{id}"]["get"].Deprecated)
+			assert.Contains(t, doc.Components.Schemas, "ErrorV2")
+			assert.NotContains(t, doc.Paths, "/docs/*")
+			assert.Contains(t, doc.Components.Schemas, "Book")
+			assert.Contains(t, doc.Components.Schemas, "Error")
+			assert.Contains(t, doc.Components.SecuritySchemes, "bearerAuth")
+			if post := doc.Paths["/v1/books"]["post"]; assert.NotNil(t, post) && assert.Len(t, post.Parameters, 1) {
+				assert.Equal(t, "Idempotency-Key", post.Parameters[0]["name"])
+				assert.Equal(t, "header", post.Parameters[0]["in"])
+				assert.Contains(t, post.Responses, "422")
+			}
+			assert.NotContains(t, doc.Paths["/v1/books/{id}"]["put"].Responses, "422")
+		}
+	})
+
+	t.Run("Valid Docs UI", func(t *testing.T) {
+		req, rec := httptest.NewRequest(http.MethodGet, "/docs/swagger-ui-bundle.js", nil), httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// CacheControl tags the successful responses of public reads with an ETag
+// and answers 304 while the one a client holds is current. Clients keep the
+// responses private and revalidate them on every use, so they see their own
+// writes at once.
+func CacheControl() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req, res := ctx.Request(), ctx.Response()
+			if req.Method != http.MethodGet && req.Method != http.MethodHead {
+				return next(ctx)
+			}
+			writer := &etagWriter{ResponseWriter: res.Writer, match: req.Header.Get("If-None-Match")}
+			res.Writer = writer
+			defer func() { res.Writer = writer.ResponseWriter }()
+			err := next(ctx)
+			if writer.close() {
+				res.Status = http.StatusNotModified
+			}
+			return err
+		}
+	}
+}
+
+// etagWriter holds back successful responses until their ETag is known
+type etagWriter struct {
+	http.ResponseWriter
+	match     string
+	code      int
+	buffer    []byte
+	buffering bool
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if code >= http.StatusOK && code < http.StatusMultipleChoices {
+		w.code, w.buffering = code, true
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if w.buffering {
+		w.buffer = append(w.buffer, b...)
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *etagWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close writes the held response, reporting true when it was not modified
+func (w *etagWriter) close() bool {
+	if !w.buffering {
+		return false
+	}
+	sum := sha256.Sum256(w.buffer)
+	// weak as the representation still changes with the encoding
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	header := w.Header()
+	header.Set(echo.HeaderCacheControl, "private, no-cache")
+	header.Set("ETag", etag)
+	if matchETag(w.match, etag) {
+		header.Del(echo.HeaderContentLength)
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+	w.ResponseWriter.Write(w.buffer)
+	return false
+}
+
+// matchETag compares an If-None-Match header with the weak comparison
+func matchETag(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/labstack/echo/v4"
+)
+
+var encoders = map[string]func(io.Writer) io.WriteCloser{
+	"gzip": func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	},
+	// a lower brotli level keeps dynamic responses cheap to compress
+	"br": func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriterLevel(w, 4)
+	},
+}
+
+// compressed are media types whose format is compressed already
+var compressed = map[string]bool{
+	"application/zip":    true,
+	"application/gzip":   true,
+	"application/x-gzip": true,
+	"application/zstd":   true,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": true,
+}
+
+// Compress encodes responses of at least minSize bytes with the first of
+// encodings preferred by the client's Accept-Encoding.
+func Compress(encodings []string, minSize int) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			res := ctx.Response()
+			res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+			encoding := negotiate(ctx.Request().Header.Get(echo.HeaderAcceptEncoding), encodings)
+			if encoding == "" || ctx.Request().Method == http.MethodHead {
+				return next(ctx)
+			}
+
+			writer := &compressWriter{ResponseWriter: res.Writer, encoding: encoding, minSize: minSize}
+			res.Writer = writer
+			defer func() {
+				writer.close()
+				res.Writer = writer.ResponseWriter
+			}()
+			return next(ctx)
+		}
+	}
+}
+
+// negotiate picks the supported encoding with the highest q-value,
+// breaking ties by the server's order of preference
+func negotiate(header string, encodings []string) string {
+	accepted := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if val, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if parsed, err := strconv.ParseFloat(val, 64); err == nil {
+				q = parsed
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+	best, bestQ := "", 0.0
+	for _, encoding := range encodings {
+		q, found := accepted[encoding]
+		if !found {
+			q, found = accepted["*"]
+		}
+		if found && q > bestQ && encoders[encoding] != nil {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the body until minSize bytes are written, then
+// decides whether the response is worth encoding
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	buffer   bytes.Buffer
+	encoder  io.WriteCloser
+	code     int
+	decided  bool
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.code = code
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	if w.Header().Get(echo.HeaderContentType) == "" {
+		w.Header().Set(echo.HeaderContentType, http.DetectContentType(b))
+	}
+	n, _ := w.buffer.Write(b)
+	if w.buffer.Len() >= w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func (w *compressWriter) Flush() {
+	// a flushed stream is compressed as its final size is unknown
+	if !w.decided {
+		w.decide(true)
+	}
+	if flusher, found := w.encoder.(interface{ Flush() error }); found {
+		flusher.Flush()
+	}
+	if flusher, found := w.ResponseWriter.(http.Flusher); found {
+		flusher.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, found := w.ResponseWriter.(http.Hijacker); found {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("[err]: response writer does not support hijacking")
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	// leave responses alone that are already encoded or carry no body
+	if header.Get(echo.HeaderContentEncoding) != "" || w.code == http.StatusNoContent || w.code == http.StatusNotModified {
+		compress = false
+	}
+	if incompressible(header.Get(echo.HeaderContentType)) {
+		compress = false
+	}
+	if compress {
+		header.Set(echo.HeaderContentEncoding, w.encoding)
+		header.Del(echo.HeaderContentLength)
+		w.encoder = encoders[w.encoding](w.ResponseWriter)
+	}
+	if w.code != 0 {
+		w.ResponseWriter.WriteHeader(w.code)
+	}
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(w.buffer.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buffer.Bytes())
+	}
+	w.buffer.Reset()
+	return err
+}
+
+func incompressible(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	kind, _, _ := strings.Cut(mediaType, "/")
+	if kind == "image" && mediaType != "image/svg+xml" || kind == "video" || kind == "audio" {
+		return true
+	}
+	return compressed[mediaType]
+}
+
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.encoder != nil {
+		w.encoder.Close()
+	}
+}
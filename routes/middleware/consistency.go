@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/helpers"
+)
+
+const consistencyCookie = "db_primary_until"
+
+func ReadYourWrites(window time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			c, consistency := helpers.WithConsistency(req.Context())
+			if window > 0 {
+				if cookie, err := ctx.Cookie(consistencyCookie); err == nil {
+					until, err := strconv.ParseInt(cookie.Value, 10, 64)
+					if err == nil && time.Now().Unix() < until {
+						consistency.UsePrimary()
+					}
+				}
+				ctx.Response().Before(func() {
+					if !consistency.Wrote() {
+						return
+					}
+					until := time.Now().Add(window)
+					ctx.SetCookie(&http.Cookie{
+						Name:     consistencyCookie,
+						Value:    strconv.FormatInt(until.Unix(), 10),
+						Path:     "/",
+						Expires:  until,
+						HttpOnly: true,
+						SameSite: http.SameSiteLaxMode,
+					})
+				})
+			}
+			ctx.SetRequest(req.WithContext(c))
+			return next(ctx)
+		}
+	}
+}
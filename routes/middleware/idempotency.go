@@ -0,0 +1,257 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/configs"
+	"github.com/rizghz/api/helpers"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKey    = 255
+	// a running request holds its key until its deadline and this long
+	// after, so a crashed instance does not block the retries until the key
+	// expires. Requests without a deadline hold it for the whole TTL.
+	idempotencyLock = time.Minute
+)
+
+// headers of the client or the connection rather than of the response
+var unreplayed = map[string]bool{
+	"Set-Cookie":       true,
+	"Content-Length":   true,
+	"Content-Encoding": true,
+}
+
+// IdempotencyRecord is a request stored under its key with its response,
+// Status stays 0 while the request runs
+type IdempotencyRecord struct {
+	Fingerprint string
+	Status      int
+	Header      http.Header
+	Body        []byte
+}
+
+func (r *IdempotencyRecord) clone() *IdempotencyRecord {
+	copied := *r
+	copied.Header = r.Header.Clone()
+	copied.Body = append([]byte(nil), r.Body...)
+	return &copied
+}
+
+// IdempotencyStore keeps the records of the requests sent with a key.
+// Reserve stores record unless key is taken and returns the record found
+// instead, shared backends must check and store atomically. Stores keep and
+// return copies, callers may change the records they pass or get.
+type IdempotencyStore interface {
+	Reserve(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, error)
+	Save(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error
+	Release(ctx context.Context, key string) error
+}
+
+type idempotencyEntry struct {
+	record  *IdempotencyRecord
+	expires time.Time
+}
+
+type IdempotencyMemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	swept   time.Time
+}
+
+func NewIdempotencyMemoryStore() *IdempotencyMemoryStore {
+	return &IdempotencyMemoryStore{
+		entries: map[string]*idempotencyEntry{},
+		swept:   time.Now(),
+	}
+}
+
+func (s *IdempotencyMemoryStore) Reserve(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+	if e, found := s.entries[key]; found && now.Before(e.expires) {
+		return e.record.clone(), nil
+	}
+	s.entries[key] = &idempotencyEntry{record: record.clone(), expires: now.Add(ttl)}
+	return nil, nil
+}
+
+func (s *IdempotencyMemoryStore) Save(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = &idempotencyEntry{record: record.clone(), expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *IdempotencyMemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep drops expired records, at most once a minute
+func (s *IdempotencyMemoryStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < time.Minute {
+		return
+	}
+	s.swept = now
+	for key, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// Idempotency stores the response of a POST or PATCH sent with an
+// Idempotency-Key and replays it when the request is retried. The key
+// answers 422 when it comes back with another request and 409 while its
+// first request still runs. Errors and 5xx responses release the key so
+// the request can be retried. Keys belong to the user, or else to the client
+// the rate limiter sees, so a retry from another network still replays.
+func Idempotency(conf *configs.IdempotencyConfig, store IdempotencyStore, clients *RateLimiter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			key := req.Header.Get(IdempotencyKeyHeader)
+			if !conf.Enabled || key == "" || req.Method != http.MethodPost && req.Method != http.MethodPatch {
+				return next(ctx)
+			}
+			if len(key) > maxIdempotencyKey {
+				return ctx.JSON(http.StatusBadRequest, helpers.FormatResponse("invalid idempotency key", nil))
+			}
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return err
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			c := req.Context()
+			// responses are only replayed to the client that sent the key
+			scope := clients.client(ctx)
+			if userId := userOf(ctx); userId != 0 {
+				scope = "user:" + strconv.Itoa(userId)
+			}
+			key = "idempotency:" + scope + ":" + key
+			lock := idempotencyLock
+			if deadline, found := c.Deadline(); found {
+				lock += time.Until(deadline)
+			} else {
+				lock = conf.TTL
+			}
+			record := &IdempotencyRecord{Fingerprint: fingerprint(req, body)}
+			stored, err := store.Reserve(c, key, record, lock)
+			if err != nil {
+				// fail open like the rate limiter rather than reject writes
+				helpers.Log(c).Warnf("idempotency store unavailable: %v", err)
+				return next(ctx)
+			}
+			switch {
+			case stored == nil:
+			case stored.Fingerprint != record.Fingerprint:
+				return ctx.JSON(http.StatusUnprocessableEntity,
+					helpers.FormatResponse("idempotency key reused with a different request", nil))
+			case stored.Status == 0:
+				return ctx.JSON(http.StatusConflict,
+					helpers.FormatResponse("request with this idempotency key is in progress", nil))
+			default:
+				return replay(ctx, stored)
+			}
+
+			res := ctx.Response()
+			writer := &recordWriter{ResponseWriter: res.Writer, before: res.Header().Clone()}
+			res.Writer = writer
+			err = next(ctx)
+			res.Writer = writer.ResponseWriter
+			if err != nil || writer.status == 0 || writer.status >= http.StatusInternalServerError {
+				if err := store.Release(c, key); err != nil {
+					helpers.Log(c).Warnf("idempotency store unavailable: %v", err)
+				}
+				return err
+			}
+			record.Status, record.Header, record.Body = writer.status, writer.header, writer.body.Bytes()
+			if err := store.Save(c, key, record, conf.TTL); err != nil {
+				helpers.Log(c).Warnf("idempotency store unavailable: %v", err)
+			}
+			return nil
+		}
+	}
+}
+
+// fingerprint identifies a request by its method, uri and body
+func fingerprint(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, req.Method+" "+req.URL.RequestURI()+"\n")
+	io.WriteString(hash, mediaType(req.Header.Get(echo.HeaderContentType))+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func replay(ctx echo.Context, record *IdempotencyRecord) error {
+	res := ctx.Response()
+	for name, values := range record.Header {
+		res.Header()[name] = append([]string(nil), values...)
+	}
+	res.Header().Set("Idempotent-Replayed", "true")
+	res.WriteHeader(record.Status)
+	_, err := res.Write(record.Body)
+	return err
+}
+
+// recordWriter keeps the status, the headers set by the handler and the
+// body of a response as they are written
+type recordWriter struct {
+	http.ResponseWriter
+	before http.Header
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *recordWriter) WriteHeader(code int) {
+	w.status, w.header = code, http.Header{}
+	for name, values := range w.Header() {
+		if !unreplayed[name] && strings.Join(values, "\n") != strings.Join(w.before[name], "\n") {
+			w.header[name] = append([]string(nil), values...)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordWriter) Flush() {
+	if flusher, found := w.ResponseWriter.(http.Flusher); found {
+		flusher.Flush()
+	}
+}
+
+func (w *recordWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, found := w.ResponseWriter.(http.Hijacker); found {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("[err]: response writer does not support hijacking")
+}
+
+func (w *recordWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -1,29 +1,72 @@
 package middleware
 
 import (
+	"errors"
+	"net/http"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/rizghz/api/configs"
 )
 
-func CreateToken(userId int) (string, error) {
+func CreateToken(conf *configs.JwtConfig, userId int) (string, error) {
 	claims := jwt.MapClaims{}
 	claims["authorized"] = true
 	claims["userId"] = userId
-	claims["expired"] = time.Now().Add(time.Hour * 2).Unix()
+	claims["exp"] = time.Now().Add(conf.Expiry).Unix()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	env, _ := configs.NewJwtEnv()
-	return token.SignedString([]byte(env["SECRET_KEY"].(string)))
+	return token.SignedString([]byte(conf.Secret))
 }
 
-func ExtractToken(ctx echo.Context) int {
-	user := ctx.Get("user").(*jwt.Token)
-	if user.Valid {
-		claims := user.Claims.(jwt.MapClaims)
-		userId := claims["userId"].(int)
-		return userId
+// ParseToken validates a token issued by CreateToken and returns its user
+func ParseToken(conf *configs.JwtConfig, tokenString string) (int, error) {
+	token, err := parseToken(conf, tokenString)
+	if err != nil {
+		return 0, err
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	userId, found := claims["userId"].(float64)
+	if !found || userId <= 0 {
+		return 0, errors.New("[err]: token has no user")
+	}
+	return int(userId), nil
+}
+
+// parseToken checks the signature and the expiry, which jwt only checks
+// when the token carries an exp claim
+func parseToken(conf *configs.JwtConfig, tokenString string) (*jwt.Token, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(conf.Secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if exp, err := token.Claims.GetExpirationTime(); err != nil || exp == nil {
+		return nil, errors.New("[err]: token has no expiry")
 	}
-	return 0
+	return token, nil
+}
+
+func ExtractToken(ctx echo.Context) int {
+	return userOf(ctx)
+}
+
+// OptionalJWT lets anonymous requests through but still rejects a token that
+// is present and invalid, leaving the resolvers to decide what needs a user
+func OptionalJWT(conf *configs.JwtConfig) echo.MiddlewareFunc {
+	return echojwt.WithConfig(echojwt.Config{
+		ParseTokenFunc: func(ctx echo.Context, auth string) (interface{}, error) {
+			return parseToken(conf, auth)
+		},
+		ContinueOnIgnoredError: true,
+		ErrorHandler: func(ctx echo.Context, err error) error {
+			var missing *echojwt.TokenExtractionError
+			if errors.As(err, &missing) {
+				return nil
+			}
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired jwt").SetInternal(err)
+		},
+	})
 }
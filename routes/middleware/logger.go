@@ -1 +1,123 @@
 package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/helpers"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestID reuses a sane X-Request-ID from the client or generates one,
+// exposing it on the response and through the request context.
+func RequestID() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			id := req.Header.Get(echo.HeaderXRequestID)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			ctx.Response().Header().Set(echo.HeaderXRequestID, id)
+			ctx.SetRequest(req.WithContext(helpers.WithRequestID(req.Context(), id)))
+			return next(ctx)
+		}
+	}
+}
+
+// Logger writes one structured access log entry per request
+func Logger() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			start := time.Now()
+			if err := next(ctx); err != nil {
+				renderError(ctx, err)
+			}
+			// middlewares further in render their errors and return nil
+			err := handlerError(ctx)
+
+			req, res := ctx.Request(), ctx.Response()
+			fields := logrus.Fields{
+				"method":     req.Method,
+				"route":      ctx.Path(),
+				"uri":        req.RequestURI,
+				"status":     res.Status,
+				"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+				"bytes_in":   req.ContentLength,
+				"bytes_out":  res.Size,
+				"remote_ip":  ctx.RealIP(),
+			}
+			if userId := userOf(ctx); userId != 0 {
+				fields["user_id"] = userId
+			}
+			entry := helpers.Log(req.Context()).WithFields(fields)
+			if err != nil {
+				entry = entry.WithError(err)
+			}
+			switch {
+			case res.Status >= http.StatusInternalServerError:
+				entry.Error("request failed")
+			case res.Status >= http.StatusBadRequest:
+				entry.Warn("request rejected")
+			default:
+				entry.Info("request served")
+			}
+			return nil
+		}
+	}
+}
+
+const handlerErrorKey = "handler_error"
+
+// renderError writes the response of err and keeps it for the middlewares
+// further out, which only see the nil returned once it is rendered
+func renderError(ctx echo.Context, err error) {
+	ctx.Set(handlerErrorKey, err)
+	ctx.Error(err)
+}
+
+func handlerError(ctx echo.Context) error {
+	err, _ := ctx.Get(handlerErrorKey).(error)
+	return err
+}
+
+func userOf(ctx echo.Context) int {
+	token, found := ctx.Get("user").(*jwt.Token)
+	if !found || !token.Valid {
+		return 0
+	}
+	claims, found := token.Claims.(jwt.MapClaims)
+	if !found {
+		return 0
+	}
+	// numeric claims are decoded from the token as float64
+	switch userId := claims["userId"].(type) {
+	case float64:
+		return int(userId)
+	case int:
+		return userId
+	}
+	return 0
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, char := range id {
+		if char < '!' || char > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/metrics"
+)
+
+func Metrics() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			metrics.HttpInFlight.Inc()
+			defer metrics.HttpInFlight.Dec()
+
+			start := time.Now()
+			if err := next(ctx); err != nil {
+				renderError(ctx, err)
+			}
+
+			// label by route template to keep cardinality bounded
+			route := ctx.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			req, status := ctx.Request(), strconv.Itoa(ctx.Response().Status)
+			metrics.HttpRequests.WithLabelValues(req.Method, route, status).Inc()
+			metrics.HttpDuration.WithLabelValues(req.Method, route, status).Observe(time.Since(start).Seconds())
+			return nil
+		}
+	}
+}
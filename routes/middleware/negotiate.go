@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/helpers"
+)
+
+// formats are offered in this order when the Accept header ties
+var formats = []string{"json", "xml", "msgpack", "csv"}
+
+// exports stream files in the format they are asked for and skip the
+// negotiation of JSON responses
+func exports(ctx echo.Context) bool {
+	return strings.HasSuffix(ctx.Path(), "/export")
+}
+
+// bodyTypes are the request bodies the Binder decodes
+var bodyTypes = map[string]bool{
+	echo.MIMEApplicationForm:  true,
+	echo.MIMEMultipartForm:    true,
+	"application/json":        true,
+	"application/xml":         true,
+	"text/xml":                true,
+	"application/msgpack":     true,
+	"application/x-msgpack":   true,
+	"application/vnd.msgpack": true,
+	"text/csv":                true,
+}
+
+// Negotiate renders the JSON responses of the handlers in the format asked
+// for by the Accept header, answering 406 when none can be produced and 415
+// for request bodies the Binder cannot decode. CSV is only produced for
+// lists, other payloads fall back to JSON when they report an error.
+func Negotiate() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req, res := ctx.Request(), ctx.Response()
+			res.Header().Add(echo.HeaderVary, echo.HeaderAccept)
+			if hasBody(req) && !bodyTypes[mediaType(req.Header.Get(echo.HeaderContentType))] {
+				return ctx.JSON(http.StatusUnsupportedMediaType,
+					helpers.FormatResponse("unsupported media type", nil))
+			}
+			if exports(ctx) {
+				return next(ctx)
+			}
+			format := helpers.NegotiateFormat(req.Header.Get(echo.HeaderAccept), formats...)
+			if format == "" {
+				return ctx.JSON(http.StatusNotAcceptable,
+					helpers.FormatResponse("not acceptable", nil))
+			}
+			if format == "json" {
+				return next(ctx)
+			}
+
+			writer := &formatWriter{ResponseWriter: res.Writer, format: format}
+			res.Writer = writer
+			defer func() {
+				writer.close()
+				res.Writer = writer.ResponseWriter
+			}()
+			// errors are rendered here so that they are converted as well
+			if err := next(ctx); err != nil {
+				renderError(ctx, err)
+			}
+			return nil
+		}
+	}
+}
+
+func hasBody(req *http.Request) bool {
+	return req.ContentLength > 0 || req.ContentLength == -1 && req.Body != nil && req.Body != http.NoBody
+}
+
+func mediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if helpers.FormatOf(mediaType) == "json" {
+		return "application/json"
+	}
+	return mediaType
+}
+
+// formatWriter holds back JSON bodies and writes them again in format once
+// the handler returns
+type formatWriter struct {
+	http.ResponseWriter
+	format    string
+	buffer    bytes.Buffer
+	code      int
+	buffering bool
+}
+
+func (w *formatWriter) WriteHeader(code int) {
+	if strings.HasPrefix(w.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		w.code, w.buffering = code, true
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *formatWriter) Write(b []byte) (int, error) {
+	if w.buffering {
+		return w.buffer.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *formatWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *formatWriter) close() {
+	if !w.buffering {
+		return
+	}
+	code, format, body := w.code, w.format, w.buffer.Bytes()
+	value, err := helpers.DecodeOrdered(bytes.NewReader(body))
+	if err == nil && format == "csv" {
+		// only the payload of the envelope is tabular
+		envelope, _ := value.(*helpers.OrderedMap)
+		value = nil
+		if envelope != nil {
+			value = envelope.Values["data"]
+		}
+	}
+	var encoded bytes.Buffer
+	if err == nil {
+		err = helpers.Encode(format, &encoded, value)
+	}
+	switch {
+	case err == nil:
+		body = encoded.Bytes()
+	case format == "csv" && code < http.StatusBadRequest:
+		code, format = http.StatusNotAcceptable, "json"
+		body = []byte(`{"message":"csv is only available for lists"}` + "\n")
+	default:
+		format = "json"
+	}
+
+	header := w.Header()
+	header.Set(echo.HeaderContentType, helpers.MediaTypes[format][0])
+	if format != "msgpack" {
+		header.Set(echo.HeaderContentType, helpers.MediaTypes[format][0]+"; charset=UTF-8")
+	}
+	w.ResponseWriter.WriteHeader(code)
+	w.ResponseWriter.Write(body)
+}
+
+// Binder decodes XML, MessagePack and CSV request bodies on top of the
+// formats echo binds by itself
+type Binder struct {
+	echo.DefaultBinder
+}
+
+func (b *Binder) Bind(i interface{}, ctx echo.Context) error {
+	req := ctx.Request()
+	if hasBody(req) {
+		switch format := helpers.FormatOf(mediaType(req.Header.Get(echo.HeaderContentType))); format {
+		case "msgpack":
+			body, err := helpers.MsgpackToJSON(req.Body)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+			}
+			replaceBody(req, echo.MIMEApplicationJSON, body)
+		case "xml", "csv":
+			values, err := helpers.DecodeForm(format, req.Body)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+			}
+			replaceBody(req, echo.MIMEApplicationForm, []byte(values.Encode()))
+		}
+	}
+	return b.DefaultBinder.Bind(i, ctx)
+}
+
+func replaceBody(req *http.Request, contentType string, body []byte) {
+	req.Header.Set(echo.HeaderContentType, contentType)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+}
@@ -0,0 +1,237 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/configs"
+	"github.com/rizghz/api/helpers"
+)
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// RateLimitStore takes one token from the bucket stored under key, a
+// bucket holding up to limit tokens and refilling them evenly over period.
+// Shared backends must apply the refill and take atomically.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit int, period time.Duration) (RateLimitResult, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*bucket{},
+		swept:   time.Now(),
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit int, period time.Duration) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+	rate := float64(limit) / period.Seconds()
+	b, found := s.buckets[key]
+	if !found {
+		b = &bucket{tokens: float64(limit), updated: now, period: period}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit), b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	res := RateLimitResult{Limit: limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((float64(limit) - b.tokens) / rate)
+	return res, nil
+}
+
+// sweep drops buckets that have refilled completely, at most once a minute
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < time.Minute {
+		return
+	}
+	s.swept = now
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) >= b.period {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+type RateLimiter struct {
+	conf  *configs.RateLimitConfig
+	store RateLimitStore
+	keys  map[string]bool
+}
+
+func NewRateLimiter(conf *configs.RateLimitConfig, store RateLimitStore) *RateLimiter {
+	keys := map[string]bool{}
+	for _, key := range conf.APIKeys {
+		keys[digest(key)] = true
+	}
+	return &RateLimiter{
+		conf:  conf,
+		store: store,
+		keys:  keys,
+	}
+}
+
+// Default limits safe methods with the read rate and the rest with the write rate
+func (l *RateLimiter) Default() echo.MiddlewareFunc {
+	read, write := l.Limit("read", l.conf.Read), l.Limit("write", l.conf.Write)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		reads, writes := read(next), write(next)
+		return func(ctx echo.Context) error {
+			switch ctx.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return reads(ctx)
+			}
+			return writes(ctx)
+		}
+	}
+}
+
+// Auth is the stricter limit for login and registration
+func (l *RateLimiter) Auth() echo.MiddlewareFunc {
+	return l.Limit("auth", l.conf.Auth)
+}
+
+// Logins charges the auth limit once per login a request attempts, for
+// endpoints such as GraphQL where a single request may carry several
+func (l *RateLimiter) Logins() echo.MiddlewareFunc {
+	limit, period, err := l.conf.Auth.Parse()
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if !l.conf.Enabled || err != nil {
+				return next(ctx)
+			}
+			req := ctx.Request()
+			ctx.SetRequest(req.WithContext(helpers.WithLoginLimit(req.Context(), func() bool {
+				res, ok := l.take(ctx.Request().Context(), "auth:"+l.client(ctx), limit, period)
+				return !ok || res.Allowed
+			})))
+			return next(ctx)
+		}
+	}
+}
+
+func (l *RateLimiter) Limit(scope string, rate configs.Rate) echo.MiddlewareFunc {
+	limit, period, err := rate.Parse()
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if !l.conf.Enabled || err != nil {
+				return next(ctx)
+			}
+			res, ok := l.take(ctx.Request().Context(), scope+":"+l.client(ctx), limit, period)
+			if !ok {
+				return next(ctx)
+			}
+
+			header := ctx.Response().Header()
+			header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit, int(period.Seconds())))
+			header.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(int(res.Reset.Seconds())))
+			if !res.Allowed {
+				header.Set(echo.HeaderRetryAfter, strconv.Itoa(int(res.RetryAfter.Seconds())))
+				return ctx.JSON(http.StatusTooManyRequests, helpers.FormatResponse("too many requests", nil))
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// AllowAuth charges the auth limit of a client served outside of echo, such
+// as a gRPC peer, and returns how long it has to wait when it is not allowed
+func (l *RateLimiter) AllowAuth(ctx context.Context, client string) (bool, time.Duration) {
+	limit, period, err := l.conf.Auth.Parse()
+	if !l.conf.Enabled || err != nil {
+		return true, 0
+	}
+	res, ok := l.take(ctx, "auth:"+client, limit, period)
+	if !ok || res.Allowed {
+		return true, 0
+	}
+	return false, res.RetryAfter
+}
+
+// take charges the bucket under key, reporting false when the store is down
+func (l *RateLimiter) take(ctx context.Context, key string, limit int, period time.Duration) (RateLimitResult, bool) {
+	res, err := l.store.Take(ctx, key, limit, period)
+	if err != nil {
+		// fail open rather than reject traffic when the store is down
+		helpers.Log(ctx).Warnf("rate limit store unavailable: %v", err)
+		return res, false
+	}
+	return res, true
+}
+
+// client keys the bucket by issued api key, then authenticated user, then
+// ip. Other api keys are ignored, as a client could send a new one each time.
+func (l *RateLimiter) client(ctx echo.Context) string {
+	if key := ctx.Request().Header.Get(l.conf.APIKeyHeader); l.conf.APIKeyHeader != "" && key != "" {
+		if sum := digest(key); l.keys[sum] {
+			return "key:" + sum[:16]
+		}
+	}
+	if userId := userOf(ctx); userId != 0 {
+		return "user:" + strconv.Itoa(userId)
+	}
+	return "ip:" + ctx.RealIP()
+}
+
+func digest(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IPExtractor takes the client address from X-Forwarded-For when the peer is
+// one of the trusted proxies, and uses the peer address otherwise
+func IPExtractor(proxies []*net.IPNet) echo.IPExtractor {
+	if len(proxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range proxies {
+		options = append(options, echo.TrustIPRange(proxy))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// seconds rounds up so clients never retry too early
+func seconds(value float64) time.Duration {
+	return time.Duration(math.Ceil(value)) * time.Second
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/rizghz/api/helpers"
+)
+
+// Timeout bounds the context of every request. Exports stream whole tables,
+// so they get their own timeout and push back the write deadline of the
+// server to match it.
+func Timeout(request time.Duration, export time.Duration) echo.MiddlewareFunc {
+	requests, exported := middleware.ContextTimeout(request), middleware.ContextTimeout(export)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		limited, streamed := requests(next), exported(next)
+		return func(ctx echo.Context) error {
+			if !exports(ctx) {
+				return limited(ctx)
+			}
+			deadline := time.Now().Add(export)
+			if err := http.NewResponseController(ctx.Response()).SetWriteDeadline(deadline); err != nil {
+				helpers.Log(ctx.Request().Context()).Warnf("cannot extend the write deadline: %v", err)
+			}
+			return streamed(ctx)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Tracing() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			parent := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+			spanCtx, span := tracing.Tracer().Start(parent, req.Method+" "+req.URL.Path,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(req.Method),
+					semconv.URLPathKey.String(req.URL.Path),
+					semconv.ClientAddressKey.String(ctx.RealIP()),
+					semconv.UserAgentOriginalKey.String(req.UserAgent()),
+				),
+			)
+			defer span.End()
+
+			ctx.SetRequest(req.WithContext(spanCtx))
+			// echo the trace id back so clients can correlate their requests
+			otel.GetTextMapPropagator().Inject(spanCtx, propagation.HeaderCarrier(ctx.Response().Header()))
+
+			if err := next(ctx); err != nil {
+				renderError(ctx, err)
+			}
+			if err := handlerError(ctx); err != nil {
+				span.RecordError(err)
+			}
+
+			// name the span after the route template once it is known
+			if route := ctx.Path(); route != "" {
+				span.SetName(req.Method + " " + route)
+				span.SetAttributes(semconv.HTTPRoute(route))
+			}
+			status := ctx.Response().Status
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+			return nil
+		}
+	}
+}
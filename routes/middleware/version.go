@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/configs"
+	"github.com/rizghz/api/helpers"
+)
+
+// matches application/vnd.rizghz.v2+json and application/json; version=2
+var (
+	vendorVersion = regexp.MustCompile(`application/vnd\.rizghz\.(v\d+)\+json`)
+	paramVersion  = regexp.MustCompile(`application/json\s*;\s*version=(\d+)`)
+	pathVersion   = regexp.MustCompile(`^/v\d+(/|$)`)
+)
+
+// Versioning routes unversioned requests under roots to the version
+// requested by the Accept header, or to the default version. It must be
+// registered with echo.Pre to run before routing.
+func Versioning(conf *configs.APIConfig, roots ...string) echo.MiddlewareFunc {
+	known := map[string]bool{}
+	for _, version := range conf.Versions {
+		known[version] = true
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			if pathVersion.MatchString(req.URL.Path) || !under(req.URL.Path, roots) {
+				return next(ctx)
+			}
+			ctx.Response().Header().Add(echo.HeaderVary, "Accept")
+			version := conf.DefaultVersion
+			if requested := acceptedVersion(req.Header.Get(echo.HeaderAccept)); requested != "" {
+				if !known[requested] {
+					return ctx.JSON(http.StatusNotAcceptable,
+						helpers.FormatResponse("unsupported api version", nil))
+				}
+				version = requested
+			}
+			req.URL.Path = "/" + version + req.URL.Path
+			if req.URL.RawPath != "" {
+				req.URL.RawPath = "/" + version + req.URL.RawPath
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// Version marks the responses of a version group, announcing deprecation
+// and reshaping the v1 envelope for later versions
+func Version(version string, conf *configs.APIConfig) echo.MiddlewareFunc {
+	deprecated := conf.Deprecated(version)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			header := ctx.Response().Header()
+			header.Set("API-Version", version)
+			if deprecated {
+				header.Set("Deprecation", "true")
+				if since, err := time.Parse(time.DateOnly, conf.DeprecatedSince); err == nil {
+					header.Set("Deprecation", "@"+strconv.FormatInt(since.Unix(), 10))
+				}
+				if sunset, err := time.Parse(time.DateOnly, conf.Sunset); err == nil {
+					header.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+				}
+				if latest := conf.Versions[len(conf.Versions)-1]; latest != version {
+					successor := "/" + latest + strings.TrimPrefix(ctx.Request().URL.Path, "/"+version)
+					header.Add("Link", "<"+successor+`>; rel="successor-version"`)
+				}
+			}
+			if version == "v1" {
+				return next(ctx)
+			}
+
+			res := ctx.Response()
+			writer := &envelopeWriter{ResponseWriter: res.Writer}
+			res.Writer = writer
+			defer func() {
+				writer.close()
+				res.Writer = writer.ResponseWriter
+			}()
+			return next(ctx)
+		}
+	}
+}
+
+func under(path string, roots []string) bool {
+	for _, root := range roots {
+		if path == root || strings.HasPrefix(path, root+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func acceptedVersion(accept string) string {
+	if match := vendorVersion.FindStringSubmatch(accept); match != nil {
+		return match[1]
+	}
+	if match := paramVersion.FindStringSubmatch(accept); match != nil {
+		return "v" + match[1]
+	}
+	return ""
+}
+
+// envelopeWriter holds back JSON bodies written in the v1 envelope and
+// rewrites them with helpers.FormatResponseV2 once the handler returns
+type envelopeWriter struct {
+	http.ResponseWriter
+	buffer    bytes.Buffer
+	code      int
+	buffering bool
+}
+
+func (w *envelopeWriter) WriteHeader(code int) {
+	if strings.HasPrefix(w.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		w.code, w.buffering = code, true
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *envelopeWriter) Write(b []byte) (int, error) {
+	if w.buffering {
+		return w.buffer.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *envelopeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *envelopeWriter) close() {
+	if !w.buffering {
+		return
+	}
+	body := w.buffer.Bytes()
+	v1 := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &v1); err == nil && v1["message"] != nil {
+		message, data := "", any(nil)
+		json.Unmarshal(v1["message"], &message)
+		if raw, found := v1["data"]; found {
+			data = raw
+		}
+		if reshaped, err := json.Marshal(helpers.FormatResponseV2(w.code, message, data)); err == nil {
+			body = append(reshaped, '\n')
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+	w.ResponseWriter.Write(body)
+}
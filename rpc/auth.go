@@ -0,0 +1,78 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rizghz/api/configs"
+	"github.com/rizghz/api/helpers"
+	"github.com/rizghz/api/pb"
+	"github.com/rizghz/api/routes/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// protected lists the methods that need a token, reads stay public as they
+// are on the REST routes
+var protected = map[string]bool{
+	pb.UserService_UpdateUser_FullMethodName: true,
+	pb.UserService_DeleteUser_FullMethodName: true,
+	pb.BookService_CreateBook_FullMethodName: true,
+	pb.BookService_UpdateBook_FullMethodName: true,
+	pb.BookService_DeleteBook_FullMethodName: true,
+	pb.BlogService_CreateBlog_FullMethodName: true,
+	pb.BlogService_UpdateBlog_FullMethodName: true,
+	pb.BlogService_DeleteBlog_FullMethodName: true,
+}
+
+type authenticator struct {
+	conf *configs.JwtConfig
+}
+
+// authenticate reads the bearer token from the authorization metadata. A
+// token that is present must be valid even on public methods.
+func (a *authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	header := metadata.ValueFromIncomingContext(ctx, "authorization")
+	if len(header) == 0 {
+		if protected[method] {
+			return nil, status.Error(codes.Unauthenticated, "missing or malformed jwt")
+		}
+		return ctx, nil
+	}
+	token, found := strings.CutPrefix(header[0], "Bearer ")
+	if !found {
+		return nil, status.Error(codes.Unauthenticated, "missing or malformed jwt")
+	}
+	userID, err := middleware.ParseToken(a.conf, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid or expired jwt")
+	}
+	return helpers.WithUserID(ctx, userID), nil
+}
+
+func (a *authenticator) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *authenticator) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
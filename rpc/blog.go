@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/rizghz/api/helpers"
+	m "github.com/rizghz/api/models"
+	"github.com/rizghz/api/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type blogServer struct {
+	pb.UnimplementedBlogServiceServer
+	model m.IBlogModel
+}
+
+func toBlog(blog *m.Blog) *pb.Blog {
+	return &pb.Blog{
+		Id:        uint64(blog.ID),
+		Title:     blog.Title,
+		Content:   blog.Content,
+		UserId:    uint64(blog.UserID),
+		CreatedAt: timestamppb.New(blog.CreatedAt),
+		UpdatedAt: timestamppb.New(blog.UpdatedAt),
+	}
+}
+
+func (s *blogServer) ListBlogs(ctx context.Context, req *pb.ListBlogsRequest) (*pb.ListBlogsResponse, error) {
+	blogs := s.model.Get(ctx)
+	res := &pb.ListBlogsResponse{Blogs: make([]*pb.Blog, len(blogs))}
+	for i := range blogs {
+		res.Blogs[i] = toBlog(&blogs[i])
+	}
+	return res, nil
+}
+
+func (s *blogServer) GetBlog(ctx context.Context, req *pb.GetBlogRequest) (*pb.Blog, error) {
+	id, err := key(req.Id, "blog")
+	if err != nil {
+		return nil, err
+	}
+	blog := s.model.Find(ctx, id)
+	if blog == nil {
+		return nil, status.Error(codes.NotFound, "blog not found")
+	}
+	return toBlog(blog), nil
+}
+
+func (s *blogServer) CreateBlog(ctx context.Context, req *pb.CreateBlogRequest) (*pb.Blog, error) {
+	blog := m.Blog{Title: req.Title, Content: req.Content, UserID: uint(helpers.UserID(ctx))}
+	if _, data := s.model.Create(ctx, &blog); data != nil {
+		return toBlog(data), nil
+	}
+	return nil, errServer
+}
+
+// owned loads a blog the authenticated user may change
+func (s *blogServer) owned(ctx context.Context, id uint64) (*m.Blog, error) {
+	key, err := key(id, "blog")
+	if err != nil {
+		return nil, err
+	}
+	blog := s.model.Find(ctx, key)
+	if blog == nil {
+		return nil, status.Error(codes.NotFound, "blog not found")
+	}
+	if int(blog.UserID) != helpers.UserID(ctx) {
+		return nil, errNotOwner
+	}
+	return blog, nil
+}
+
+func (s *blogServer) UpdateBlog(ctx context.Context, req *pb.UpdateBlogRequest) (*pb.Blog, error) {
+	blog, err := s.owned(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	blog.Title, blog.Content = req.Title, req.Content
+	if _, data := s.model.Update(ctx, blog); data != nil {
+		return toBlog(data), nil
+	}
+	return nil, errServer
+}
+
+func (s *blogServer) DeleteBlog(ctx context.Context, req *pb.DeleteBlogRequest) (*emptypb.Empty, error) {
+	blog, err := s.owned(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	id := int(blog.ID)
+	if !s.model.Delete(ctx, &id) {
+		return nil, errServer
+	}
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,85 @@
+package rpc
+
+import (
+	"context"
+
+	m "github.com/rizghz/api/models"
+	"github.com/rizghz/api/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type bookServer struct {
+	pb.UnimplementedBookServiceServer
+	model m.IBookModel
+}
+
+func toBook(book *m.Book) *pb.Book {
+	return &pb.Book{
+		Id:        uint64(book.ID),
+		Isbn:      book.ISBN,
+		Title:     book.Title,
+		Author:    book.Author,
+		Publisher: book.Publisher,
+		CreatedAt: timestamppb.New(book.CreatedAt),
+		UpdatedAt: timestamppb.New(book.UpdatedAt),
+	}
+}
+
+func (s *bookServer) ListBooks(ctx context.Context, req *pb.ListBooksRequest) (*pb.ListBooksResponse, error) {
+	books := s.model.Get(ctx)
+	res := &pb.ListBooksResponse{Books: make([]*pb.Book, len(books))}
+	for i := range books {
+		res.Books[i] = toBook(&books[i])
+	}
+	return res, nil
+}
+
+func (s *bookServer) GetBook(ctx context.Context, req *pb.GetBookRequest) (*pb.Book, error) {
+	id, err := key(req.Id, "book")
+	if err != nil {
+		return nil, err
+	}
+	book := s.model.Find(ctx, id)
+	if book == nil {
+		return nil, status.Error(codes.NotFound, "book not found")
+	}
+	return toBook(book), nil
+}
+
+func (s *bookServer) CreateBook(ctx context.Context, req *pb.CreateBookRequest) (*pb.Book, error) {
+	book := m.Book{ISBN: req.Isbn, Title: req.Title, Author: req.Author, Publisher: req.Publisher}
+	if _, data := s.model.Create(ctx, &book); data != nil {
+		return toBook(data), nil
+	}
+	return nil, errServer
+}
+
+func (s *bookServer) UpdateBook(ctx context.Context, req *pb.UpdateBookRequest) (*pb.Book, error) {
+	id, err := key(req.Id, "book")
+	if err != nil {
+		return nil, err
+	}
+	book := s.model.Find(ctx, id)
+	if book == nil {
+		return nil, status.Error(codes.NotFound, "book not found")
+	}
+	book.ISBN, book.Title, book.Author, book.Publisher = req.Isbn, req.Title, req.Author, req.Publisher
+	if _, data := s.model.Update(ctx, book); data != nil {
+		return toBook(data), nil
+	}
+	return nil, errServer
+}
+
+func (s *bookServer) DeleteBook(ctx context.Context, req *pb.DeleteBookRequest) (*emptypb.Empty, error) {
+	id, err := key(req.Id, "book")
+	if err != nil {
+		return nil, err
+	}
+	if !s.model.Delete(ctx, id) {
+		return nil, errServer
+	}
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,46 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	"github.com/rizghz/api/pb"
+	"github.com/rizghz/api/routes/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// limited lists the methods charged to the auth limit, like the login and
+// registration routes
+var limited = map[string]bool{
+	pb.UserService_Login_FullMethodName:      true,
+	pb.UserService_CreateUser_FullMethodName: true,
+}
+
+type limiter struct {
+	limiter *middleware.RateLimiter
+}
+
+// unary charges the peer address, the server is not meant to sit behind a
+// proxy that would hide it
+func (l *limiter) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !limited[info.FullMethod] {
+		return handler(ctx, req)
+	}
+	client := "unknown"
+	if p, found := peer.FromContext(ctx); found {
+		client = p.Addr.String()
+		if host, _, err := net.SplitHostPort(client); err == nil {
+			client = host
+		}
+	}
+	if allowed, retry := l.limiter.AllowAuth(ctx, "ip:"+client); !allowed {
+		grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(retry.Seconds()))))
+		return nil, status.Error(codes.ResourceExhausted, "too many requests")
+	}
+	return handler(ctx, req)
+}
@@ -0,0 +1,253 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/configs"
+	"github.com/rizghz/api/controllers"
+	"github.com/rizghz/api/models"
+	"github.com/rizghz/api/pb"
+	"github.com/rizghz/api/routes"
+	"github.com/rizghz/api/routes/middleware"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+var testJwt = &configs.JwtConfig{Secret: "rahasia", Expiry: time.Hour}
+
+// api serves the same models over REST and gRPC so every case can compare
+// both transports against one database
+type api struct {
+	echo  *echo.Echo
+	conn  *grpc.ClientConn
+	users models.IUserModel
+	books models.IBookModel
+	blogs models.IBlogModel
+}
+
+func newTestAPI(t *testing.T, rate *configs.RateLimitConfig) *api {
+	db, err := models.Init(&configs.DatabaseConfig{
+		Driver:       "sqlite",
+		DSN:          fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ToLower(t.Name())),
+		QueryTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	a := &api{
+		echo:  echo.New(),
+		users: models.NewUserModel(db, testJwt),
+		books: models.NewBookModel(db),
+		blogs: models.NewBlogModel(db),
+	}
+
+	limiter := middleware.NewRateLimiter(rate, middleware.NewMemoryStore())
+	idempotent := middleware.Idempotency(&configs.IdempotencyConfig{}, middleware.NewIdempotencyMemoryStore(), limiter)
+	limits := &configs.BodyLimitConfig{Users: "1M", Books: "1M", Blogs: "1M", Import: "1M"}
+	v1 := a.echo.Group("/v1")
+	routes.UserRoute(v1, controllers.NewUserController(a.users), testJwt, limiter, limits, idempotent)
+	routes.BookRoute(v1, controllers.NewBookController(a.books), limiter, limits, idempotent)
+	routes.BlogRoute(v1, controllers.NewBlogController(a.blogs), limiter, limits, idempotent)
+
+	lis := bufconn.Listen(1 << 20)
+	server := NewServer(&configs.GRPCConfig{}, testJwt, limiter, a.users, a.books, a.blogs)
+	go server.Serve(lis)
+	a.conn, err = grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		a.conn.Close()
+		server.Stop()
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return a
+}
+
+// rest calls a REST route and decodes the data of the v1 envelope into data
+func (a *api) rest(method string, target string, body string, data any) int {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	a.echo.ServeHTTP(rec, req)
+	res := struct {
+		Data json.RawMessage `json:"data"`
+	}{}
+	if json.Unmarshal(rec.Body.Bytes(), &res) == nil && data != nil {
+		json.Unmarshal(res.Data, data)
+	}
+	return rec.Code
+}
+
+func withToken(ctx context.Context, userID int) context.Context {
+	token, _ := middleware.CreateToken(testJwt, userID)
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func TestParity(t *testing.T) {
+	a := newTestAPI(t, &configs.RateLimitConfig{})
+	ctx := context.Background()
+	_, user := a.users.Create(ctx, &models.User{Name: "Rizki", Email: "rizki@mail.id", Password: "rahasia"})
+	for i := 0; i < 3; i++ {
+		a.books.Create(ctx, &models.Book{ISBN: fmt.Sprint(i), Title: "Buku", Author: "Penulis", Publisher: "Penerbit"})
+	}
+	_, blog := a.blogs.Create(ctx, &models.Blog{Title: "Judul", Content: "Isi", UserID: user.ID})
+	books, blogs, users := pb.NewBookServiceClient(a.conn), pb.NewBlogServiceClient(a.conn), pb.NewUserServiceClient(a.conn)
+
+	t.Run("Valid Parity List Books", func(t *testing.T) {
+		data := []models.Book{}
+		assert.Equal(t, http.StatusOK, a.rest(http.MethodGet, "/v1/books", "", &data))
+		res, err := books.ListBooks(ctx, &pb.ListBooksRequest{})
+		if assert.NoError(t, err) && assert.Len(t, res.Books, len(data)) {
+			for i := range data {
+				assert.True(t, proto.Equal(toBook(&data[i]), res.Books[i]), "book %d differs", i)
+			}
+		}
+	})
+
+	t.Run("Valid Parity Get Blog", func(t *testing.T) {
+		data := models.Blog{}
+		assert.Equal(t, http.StatusOK, a.rest(http.MethodGet, fmt.Sprintf("/v1/blogs/%d", blog.ID), "", &data))
+		res, err := blogs.GetBlog(ctx, &pb.GetBlogRequest{Id: uint64(blog.ID)})
+		if assert.NoError(t, err) {
+			assert.True(t, proto.Equal(toBlog(&data), res))
+		}
+	})
+
+	t.Run("Valid Parity Create Book", func(t *testing.T) {
+		data := models.Book{}
+		body := `{"isbn":"978","title":"Baru","author":"Penulis","publisher":"Penerbit"}`
+		assert.Equal(t, http.StatusCreated, a.rest(http.MethodPost, "/v1/books", body, &data))
+		res, err := books.CreateBook(withToken(ctx, int(user.ID)), &pb.CreateBookRequest{Isbn: "978", Title: "Baru", Author: "Penulis", Publisher: "Penerbit"})
+		if assert.NoError(t, err) {
+			expected := toBook(&data)
+			expected.Id, expected.CreatedAt, expected.UpdatedAt = res.Id, res.CreatedAt, res.UpdatedAt
+			assert.True(t, proto.Equal(expected, res))
+			assert.NotEqual(t, uint64(data.ID), res.Id)
+		}
+	})
+
+	t.Run("Valid Parity Login", func(t *testing.T) {
+		data := models.User{}
+		body := fmt.Sprintf(`{"email":%q,"password":"rahasia"}`, user.Email)
+		assert.Equal(t, http.StatusOK, a.rest(http.MethodGet, "/v1/users/login", body, &data))
+		res, err := users.Login(ctx, &pb.LoginRequest{Email: user.Email, Password: "rahasia"})
+		if assert.NoError(t, err) {
+			assert.NotEmpty(t, res.Token)
+			assert.Equal(t, uint64(data.ID), res.User.Id)
+			assert.Equal(t, data.Email, res.User.Email)
+		}
+	})
+
+	t.Run("Invalid Parity Get Book (id)", func(t *testing.T) {
+		code := a.rest(http.MethodGet, "/v1/books/abc", "", nil)
+		_, err := books.GetBook(ctx, &pb.GetBookRequest{})
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, code, runtime.HTTPStatusFromCode(status.Code(err)))
+	})
+
+	t.Run("Invalid Parity Login (password)", func(t *testing.T) {
+		body := fmt.Sprintf(`{"email":%q,"password":"salah"}`, user.Email)
+		code := a.rest(http.MethodGet, "/v1/users/login", body, nil)
+		_, err := users.Login(ctx, &pb.LoginRequest{Email: user.Email, Password: "salah"})
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, code, runtime.HTTPStatusFromCode(status.Code(err)))
+	})
+}
+
+func TestAuth(t *testing.T) {
+	a := newTestAPI(t, &configs.RateLimitConfig{})
+	ctx := context.Background()
+	_, owner := a.users.Create(ctx, &models.User{Name: "Pemilik", Email: "pemilik@mail.id", Password: "rahasia"})
+	blogs := pb.NewBlogServiceClient(a.conn)
+
+	t.Run("Valid Auth Create Blog", func(t *testing.T) {
+		res, err := blogs.CreateBlog(withToken(ctx, int(owner.ID)), &pb.CreateBlogRequest{Title: "Judul", Content: "Isi"})
+		if assert.NoError(t, err) {
+			assert.Equal(t, uint64(owner.ID), res.UserId)
+		}
+	})
+
+	t.Run("Invalid Auth Create Blog (missing token)", func(t *testing.T) {
+		_, err := blogs.CreateBlog(ctx, &pb.CreateBlogRequest{Title: "Judul", Content: "Isi"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Invalid Auth List Blogs (bad token)", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer palsu")
+		_, err := blogs.ListBlogs(ctx, &pb.ListBlogsRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Invalid Auth Create Blog (expired token)", func(t *testing.T) {
+		token, _ := middleware.CreateToken(&configs.JwtConfig{Secret: testJwt.Secret, Expiry: -time.Minute}, int(owner.ID))
+		ctx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+		_, err := blogs.CreateBlog(ctx, &pb.CreateBlogRequest{Title: "Judul", Content: "Isi"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Invalid Auth Create Blog (token without exp)", func(t *testing.T) {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"userId": owner.ID}).SignedString([]byte(testJwt.Secret))
+		ctx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+		_, err := blogs.CreateBlog(ctx, &pb.CreateBlogRequest{Title: "Judul", Content: "Isi"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Invalid Auth Delete Blog (not the owner)", func(t *testing.T) {
+		_, blog := a.blogs.Create(ctx, &models.Blog{Title: "Judul", Content: "Isi", UserID: owner.ID})
+		_, err := blogs.DeleteBlog(withToken(ctx, int(owner.ID)+1), &pb.DeleteBlogRequest{Id: uint64(blog.ID)})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
+
+func TestLimit(t *testing.T) {
+	a := newTestAPI(t, &configs.RateLimitConfig{Enabled: true, Read: "100/1m", Write: "100/1m", Auth: "2/1m"})
+	ctx := context.Background()
+	a.users.Create(ctx, &models.User{Name: "Penulis", Email: "penulis@mail.id", Password: "rahasia"})
+	users := pb.NewUserServiceClient(a.conn)
+
+	t.Run("Valid Limit Login", func(t *testing.T) {
+		_, err := users.Login(ctx, &pb.LoginRequest{Email: "penulis@mail.id", Password: "salah"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		_, err = users.Login(ctx, &pb.LoginRequest{Email: "penulis@mail.id", Password: "rahasia"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Invalid Limit Login (too many attempts)", func(t *testing.T) {
+		header := metadata.MD{}
+		_, err := users.Login(ctx, &pb.LoginRequest{Email: "penulis@mail.id", Password: "rahasia"}, grpc.Header(&header))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.NotEmpty(t, header.Get("retry-after"))
+		_, err = users.CreateUser(ctx, &pb.CreateUserRequest{Name: "Baru", Email: "baru@mail.id", Password: "rahasia"})
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err), "registration shares the auth limit")
+	})
+
+	t.Run("Valid Limit List Users (not charged)", func(t *testing.T) {
+		_, err := users.ListUsers(ctx, &pb.ListUsersRequest{})
+		assert.NoError(t, err)
+	})
+}
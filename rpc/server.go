@@ -0,0 +1,73 @@
+// Package rpc serves the gRPC API on top of the same models as the REST
+// controllers.
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/rizghz/api/configs"
+	"github.com/rizghz/api/helpers"
+	m "github.com/rizghz/api/models"
+	"github.com/rizghz/api/pb"
+	"github.com/rizghz/api/routes/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	errServer   = status.Error(codes.Internal, "server error")
+	errNotOwner = status.Error(codes.PermissionDenied, "not allowed to change this resource")
+)
+
+func NewServer(conf *configs.GRPCConfig, jwt *configs.JwtConfig, limits *middleware.RateLimiter, users m.IUserModel, books m.IBookModel, blogs m.IBlogModel) *grpc.Server {
+	auth, limit := &authenticator{conf: jwt}, &limiter{limiter: limits}
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(logUnary, limit.unary, auth.unary),
+		grpc.ChainStreamInterceptor(auth.stream),
+	)
+	pb.RegisterUserServiceServer(server, &userServer{model: users})
+	pb.RegisterBookServiceServer(server, &bookServer{model: books})
+	pb.RegisterBlogServiceServer(server, &blogServer{model: blogs})
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	if conf.Reflection {
+		reflection.Register(server)
+	}
+	return server
+}
+
+// logUnary writes one access log entry per call, like middleware.Logger
+func logUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	res, err := handler(ctx, req)
+	code := status.Code(err)
+	fields := map[string]interface{}{
+		"method":     info.FullMethod,
+		"code":       code.String(),
+		"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+	}
+	entry := helpers.Log(ctx).WithFields(fields)
+	switch code {
+	case codes.OK:
+		entry.Info("call served")
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		entry.Error("call failed")
+	default:
+		entry.Warn("call rejected")
+	}
+	return res, err
+}
+
+// key converts a request id to the *int the models take, rejecting 0 the
+// way the REST controllers reject an id that is not a number
+func key(id uint64, resource string) (*int, error) {
+	if id == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid %s id", resource)
+	}
+	key := int(id)
+	return &key, nil
+}
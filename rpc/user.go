@@ -0,0 +1,111 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/rizghz/api/helpers"
+	"github.com/rizghz/api/metrics"
+	m "github.com/rizghz/api/models"
+	"github.com/rizghz/api/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type userServer struct {
+	pb.UnimplementedUserServiceServer
+	model m.IUserModel
+}
+
+func toUser(user *m.User) *pb.User {
+	return &pb.User{
+		Id:        uint64(user.ID),
+		Name:      user.Name,
+		Email:     user.Email,
+		CreatedAt: timestamppb.New(user.CreatedAt),
+		UpdatedAt: timestamppb.New(user.UpdatedAt),
+	}
+}
+
+func (s *userServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.LoginResponse, error) {
+	user, err := s.model.Check(ctx, &m.User{Email: req.Email, Password: req.Password})
+	if err != nil {
+		metrics.LoginAttempts.WithLabelValues("failure").Inc()
+		return nil, status.Error(codes.InvalidArgument, "invalid email or password")
+	}
+	metrics.LoginAttempts.WithLabelValues("success").Inc()
+	return &pb.LoginResponse{Token: user.Token, User: toUser(user)}, nil
+}
+
+func (s *userServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	users := s.model.Get(ctx)
+	res := &pb.ListUsersResponse{Users: make([]*pb.User, len(users))}
+	for i := range users {
+		res.Users[i] = toUser(&users[i])
+	}
+	return res, nil
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
+	id, err := key(req.Id, "user")
+	if err != nil {
+		return nil, err
+	}
+	user := s.model.Find(ctx, id)
+	if user == nil {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	return toUser(user), nil
+}
+
+func (s *userServer) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.User, error) {
+	user := m.User{Name: req.Name, Email: req.Email, Password: req.Password}
+	if _, data := s.model.Create(ctx, &user); data != nil {
+		return toUser(data), nil
+	}
+	return nil, errServer
+}
+
+// self loads the authenticated user, who may only change their own account
+func (s *userServer) self(ctx context.Context, id uint64) (*m.User, error) {
+	key, err := key(id, "user")
+	if err != nil {
+		return nil, err
+	}
+	if *key != helpers.UserID(ctx) {
+		return nil, errNotOwner
+	}
+	user := s.model.Find(ctx, key)
+	if user == nil {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	return user, nil
+}
+
+func (s *userServer) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.User, error) {
+	user, err := s.self(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	user.Name, user.Email = req.Name, req.Email
+	if req.Password != "" {
+		user.Password = req.Password
+	}
+	if _, data := s.model.Update(ctx, user); data != nil {
+		return toUser(data), nil
+	}
+	return nil, errServer
+}
+
+func (s *userServer) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*emptypb.Empty, error) {
+	user, err := s.self(ctx, req.Id)
+	if err != nil {
+		return nil, err
+	}
+	id := int(user.ID)
+	if !s.model.Delete(ctx, &id) {
+		return nil, errServer
+	}
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,220 @@
+// Package search keeps an embedded full-text index of the books and blogs
+// on local disk, in sync with the models through their hooks
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/rizghz/api/configs"
+	"github.com/rizghz/api/helpers"
+	"github.com/rizghz/api/models"
+)
+
+const batchSize = 500
+
+// Index is the embedded index, it implements models.ISearchIndex and
+// models.Hook
+type Index struct {
+	index     bleve.Index
+	fuzziness int
+	facetSize int
+}
+
+// Open opens the index at the configured path, creating it when missing
+func Open(conf *configs.SearchConfig) (*Index, error) {
+	index, err := bleve.Open(conf.IndexPath)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		index, err = bleve.New(conf.IndexPath, indexMapping())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[err]: cannot open search index %s: %w", conf.IndexPath, err)
+	}
+	return &Index{index: index, fuzziness: conf.Fuzziness, facetSize: conf.FacetSize}, nil
+}
+
+func (i *Index) Close() error {
+	return i.index.Close()
+}
+
+// Count returns the number of documents in the index
+func (i *Index) Count() (uint64, error) {
+	return i.index.DocCount()
+}
+
+func indexMapping() mapping.IndexMapping {
+	text := func() *mapping.FieldMapping {
+		field := bleve.NewTextFieldMapping()
+		field.Analyzer = "standard"
+		return field
+	}
+	keyword := func(name string) *mapping.FieldMapping {
+		field := bleve.NewKeywordFieldMapping()
+		field.Name = name
+		return field
+	}
+	stored := func(field *mapping.FieldMapping) *mapping.FieldMapping {
+		field.Index, field.IncludeInAll = false, false
+		return field
+	}
+
+	book := bleve.NewDocumentStaticMapping()
+	book.AddFieldMappingsAt("type", keyword("type"))
+	book.AddFieldMappingsAt("title", text())
+	// facets count whole values, the keyword copies keep them unsplit
+	book.AddFieldMappingsAt("author", text(), keyword("author_facet"))
+	book.AddFieldMappingsAt("publisher", text(), keyword("publisher_facet"))
+	book.AddFieldMappingsAt("isbn", keyword("isbn"))
+	book.AddFieldMappingsAt("year", keyword("year"))
+	book.AddFieldMappingsAt("created_at", stored(bleve.NewDateTimeFieldMapping()))
+	book.AddFieldMappingsAt("updated_at", stored(bleve.NewDateTimeFieldMapping()))
+
+	blog := bleve.NewDocumentStaticMapping()
+	blog.AddFieldMappingsAt("type", keyword("type"))
+	blog.AddFieldMappingsAt("title", text())
+	blog.AddFieldMappingsAt("content", text())
+	blog.AddFieldMappingsAt("user_id", stored(bleve.NewNumericFieldMapping()))
+	blog.AddFieldMappingsAt("year", keyword("year"))
+	blog.AddFieldMappingsAt("created_at", stored(bleve.NewDateTimeFieldMapping()))
+	blog.AddFieldMappingsAt("updated_at", stored(bleve.NewDateTimeFieldMapping()))
+
+	index := bleve.NewIndexMapping()
+	index.TypeField = "type"
+	index.AddDocumentMapping("books", book)
+	index.AddDocumentMapping("blogs", blog)
+	index.DefaultMapping = bleve.NewDocumentDisabledMapping()
+	return index
+}
+
+// document returns the id and the fields indexed for a book or a blog
+func document(record any) (string, map[string]any, bool) {
+	id, doc := "", map[string]any(nil)
+	switch record := record.(type) {
+	case *models.Book:
+		id, doc = docID("books", record.ID), map[string]any{
+			"type":       "books",
+			"title":      record.Title,
+			"author":     record.Author,
+			"publisher":  record.Publisher,
+			"isbn":       record.ISBN,
+			"year":       year(record.CreatedAt),
+			"created_at": record.CreatedAt,
+			"updated_at": record.UpdatedAt,
+		}
+	case *models.Blog:
+		id, doc = docID("blogs", record.ID), map[string]any{
+			"type":       "blogs",
+			"title":      record.Title,
+			"content":    record.Content,
+			"user_id":    record.UserID,
+			"year":       year(record.CreatedAt),
+			"created_at": record.CreatedAt,
+			"updated_at": record.UpdatedAt,
+		}
+	default:
+		return "", nil, false
+	}
+	// records saved without their creation date have no year to count
+	if doc["year"] == "" {
+		delete(doc, "year")
+	}
+	return id, doc, true
+}
+
+func docID(resource string, id uint) string {
+	return resource + "/" + strconv.FormatUint(uint64(id), 10)
+}
+
+func parseDocID(id string) (string, uint, bool) {
+	resource, key, found := strings.Cut(id, "/")
+	parsed, err := strconv.ParseUint(key, 10, 64)
+	return resource, uint(parsed), found && err == nil
+}
+
+func year(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return strconv.Itoa(t.Year())
+}
+
+// AfterSave indexes a created or updated record, failures are logged as the
+// write already went through and a reindex repairs the index
+func (i *Index) AfterSave(ctx context.Context, record any) {
+	id, doc, found := document(record)
+	if !found {
+		return
+	}
+	if err := i.index.Index(id, doc); err != nil {
+		helpers.Log(ctx).Error(err.Error())
+	}
+}
+
+func (i *Index) AfterDelete(ctx context.Context, record any) {
+	id, _, found := document(record)
+	if !found {
+		return
+	}
+	if err := i.index.Delete(id); err != nil {
+		helpers.Log(ctx).Error(err.Error())
+	}
+}
+
+// Rebuild builds a new index at the configured path from the database and
+// replaces the previous one once it is complete
+func Rebuild(ctx context.Context, conf *configs.SearchConfig, books models.IBookModel, blogs models.IBlogModel) (int, error) {
+	// a running server holds the lock of the index and would keep the old one
+	if current, err := bleve.OpenUsing(conf.IndexPath, map[string]any{"bolt_timeout": "1s"}); err == nil {
+		current.Close()
+	} else if !errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		return 0, fmt.Errorf("[err]: search index %s is in use or unreadable: %w", conf.IndexPath, err)
+	}
+	building := conf.IndexPath + ".new"
+	if err := os.RemoveAll(building); err != nil {
+		return 0, err
+	}
+	index, err := bleve.New(building, indexMapping())
+	if err != nil {
+		return 0, err
+	}
+	count, batch := 0, index.NewBatch()
+	add := func(record any) error {
+		id, doc, _ := document(record)
+		if err := batch.Index(id, doc); err != nil {
+			return err
+		}
+		if count++; batch.Size() >= batchSize {
+			err := index.Batch(batch)
+			batch.Reset()
+			return err
+		}
+		return nil
+	}
+
+	err = books.Each(ctx, func(book *models.Book) error { return add(book) })
+	if err == nil {
+		err = blogs.Each(ctx, func(blog *models.Blog) error { return add(blog) })
+	}
+	if err == nil {
+		err = index.Batch(batch)
+	}
+	if closeErr := index.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.RemoveAll(building)
+		return 0, err
+	}
+
+	if err := os.RemoveAll(conf.IndexPath); err != nil {
+		return 0, err
+	}
+	return count, os.Rename(building, conf.IndexPath)
+}
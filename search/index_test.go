@@ -0,0 +1,129 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rizghz/api/configs"
+	"github.com/rizghz/api/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestConfig(t *testing.T) *configs.SearchConfig {
+	return &configs.SearchConfig{
+		IndexEnabled: true,
+		IndexPath:    filepath.Join(t.TempDir(), "search.bleve"),
+		Fuzziness:    1,
+		FacetSize:    10,
+	}
+}
+
+func newTestModels(t *testing.T, hooks ...models.Hook) (models.IBookModel, models.IBlogModel) {
+	db, err := models.Init(&configs.DatabaseConfig{
+		Driver:       "sqlite",
+		DSN:          "file:" + t.Name() + "?mode=memory&cache=shared",
+		QueryTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	return models.NewBookModel(db, hooks...), models.NewBlogModel(db, hooks...)
+}
+
+func searchFor(text string, filters map[string]string) models.SearchQuery {
+	return models.SearchQuery{Text: text, Page: 1, PerPage: 10, Filters: filters}
+}
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+	index, err := Open(newTestConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+	books, blogs := newTestModels(t, index)
+
+	_, golang := books.Create(ctx, &models.Book{Title: "Programming in Go", Author: "Mark Summerfield", Publisher: "Addison-Wesley"})
+	books.Create(ctx, &models.Book{Title: "Programming Rust", Author: "Jim Blandy", Publisher: "O'Reilly"})
+	books.Create(ctx, &models.Book{Title: "Learning Go", Author: "Jon Bodner", Publisher: "O'Reilly"})
+	blogs.Create(ctx, &models.Blog{Title: "Notes", Content: "Programming <Go> is fun", UserID: 1})
+
+	t.Run("Valid Index Search", func(t *testing.T) {
+		result := index.Search(ctx, searchFor("programming", nil))
+		if assert.NotNil(t, result) {
+			assert.EqualValues(t, 3, result.Total)
+			assert.Equal(t, []models.Facet{{Value: "O'Reilly", Count: 1}, {Value: "Addison-Wesley", Count: 1}},
+				sorted(result.Facets["publisher"]))
+		}
+	})
+
+	t.Run("Valid Index Search Fuzzy", func(t *testing.T) {
+		result := index.Search(ctx, searchFor("progamming go", nil))
+		if assert.NotNil(t, result) && assert.Len(t, result.Hits, 2) {
+			for _, hit := range result.Hits {
+				assert.Contains(t, hit.Snippet, "<mark>")
+			}
+		}
+	})
+
+	t.Run("Valid Index Search Filter", func(t *testing.T) {
+		result := index.Search(ctx, searchFor("go", map[string]string{"publisher": "O'Reilly"}))
+		if assert.NotNil(t, result) && assert.Len(t, result.Hits, 1) {
+			book, _ := result.Hits[0].Record.(models.Book)
+			assert.Equal(t, "Learning Go", book.Title)
+			assert.Equal(t, "Jon Bodner", book.Author)
+			assert.False(t, book.CreatedAt.IsZero())
+		}
+		blog := index.Search(ctx, searchFor("go", map[string]string{"type": "blogs"}))
+		if assert.NotNil(t, blog) && assert.Len(t, blog.Hits, 1) {
+			assert.Equal(t, "blogs", blog.Hits[0].Resource)
+			assert.Equal(t, "Programming &lt;<mark>Go</mark>&gt; is fun", blog.Hits[0].Snippet)
+		}
+	})
+
+	t.Run("Valid Index Delete", func(t *testing.T) {
+		id := int(golang.ID)
+		books.Delete(ctx, &id)
+		result := index.Search(ctx, searchFor("summerfield", nil))
+		if assert.NotNil(t, result) {
+			assert.Empty(t, result.Hits)
+		}
+	})
+}
+
+func TestRebuild(t *testing.T) {
+	ctx := context.Background()
+	conf := newTestConfig(t)
+	books, blogs := newTestModels(t)
+	books.Create(ctx, &models.Book{Title: "Learning Go", Author: "Jon Bodner", Publisher: "O'Reilly"})
+	blogs.Create(ctx, &models.Blog{Title: "Notes", Content: "Go is fun", UserID: 1})
+
+	count, err := Rebuild(ctx, conf, books, blogs)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, count)
+	}
+	index, err := Open(conf)
+	if assert.NoError(t, err) {
+		defer index.Close()
+		documents, _ := index.Count()
+		assert.EqualValues(t, 2, documents)
+		assert.Len(t, index.Search(ctx, searchFor("go", nil)).Hits, 2)
+	}
+}
+
+// sorted orders facets by count then value, as ties come back in any order
+func sorted(facets []models.Facet) []models.Facet {
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Value > facets[j].Value
+	})
+	return facets
+}
@@ -0,0 +1,135 @@
+package search
+
+import (
+	"context"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/rizghz/api/helpers"
+	"github.com/rizghz/api/models"
+)
+
+// boosts weighs the fields a word of the query is searched in
+var boosts = map[string]float64{"title": 3, "author": 2, "publisher": 1, "content": 1}
+
+// facets are counted for every search, filters narrow the hits to a value
+// of a facet or to a type
+var (
+	facets  = map[string]string{"author": "author_facet", "publisher": "publisher_facet", "year": "year"}
+	filters = map[string]string{"type": "type", "author": "author_facet", "publisher": "publisher_facet", "year": "year"}
+)
+
+// Search finds the hits of every word of the query, tolerating typos in the
+// longer words, and counts the facets of all the hits
+func (i *Index) Search(ctx context.Context, q models.SearchQuery) *models.SearchResult {
+	conjuncts := []query.Query{}
+	for _, term := range q.Terms() {
+		fields := []query.Query{}
+		for field, boost := range boosts {
+			exact := bleve.NewMatchQuery(term)
+			exact.SetField(field)
+			exact.SetBoost(boost)
+			fields = append(fields, exact)
+			if fuzziness := i.fuzzinessOf(term); fuzziness > 0 {
+				// a corrected word ranks below an exact one
+				fuzzy := bleve.NewMatchQuery(term)
+				fuzzy.SetField(field)
+				fuzzy.SetFuzziness(fuzziness)
+				fuzzy.SetBoost(boost / 2)
+				fields = append(fields, fuzzy)
+			}
+		}
+		conjuncts = append(conjuncts, bleve.NewDisjunctionQuery(fields...))
+	}
+	for name, value := range q.Filters {
+		filter := bleve.NewTermQuery(value)
+		filter.SetField(filters[name])
+		filter.SetBoost(0)
+		conjuncts = append(conjuncts, filter)
+	}
+
+	req := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(conjuncts...), q.PerPage, (q.Page-1)*q.PerPage, false)
+	req.Fields = []string{"*"}
+	req.Highlight = bleve.NewHighlightWithStyle("html")
+	req.Highlight.Fields = []string{"content", "title", "author", "publisher"}
+	for name, field := range facets {
+		req.AddFacet(name, bleve.NewFacetRequest(field, i.facetSize))
+	}
+	res, err := i.index.SearchInContext(ctx, req)
+	if err != nil {
+		helpers.Log(ctx).Error(err.Error())
+		return nil
+	}
+
+	result := &models.SearchResult{
+		Hits:    []models.SearchHit{},
+		Total:   int64(res.Total),
+		Page:    q.Page,
+		PerPage: q.PerPage,
+		Facets:  map[string][]models.Facet{},
+	}
+	for _, match := range res.Hits {
+		resource, id, found := parseDocID(match.ID)
+		if !found {
+			continue
+		}
+		hit := models.SearchHit{Resource: resource, ID: id, Score: match.Score, Record: record(resource, id, match.Fields)}
+		for _, field := range req.Highlight.Fields {
+			if fragments := match.Fragments[field]; len(fragments) > 0 {
+				hit.Snippet = fragments[0]
+				break
+			}
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+	for name, facet := range res.Facets {
+		counts := []models.Facet{}
+		if facet.Terms != nil {
+			for _, term := range facet.Terms.Terms() {
+				counts = append(counts, models.Facet{Value: term.Term, Count: term.Count})
+			}
+		}
+		result.Facets[name] = counts
+	}
+	return result
+}
+
+// fuzzinessOf tolerates a typo from 4 letters and two from 8, up to the
+// configured fuzziness
+func (i *Index) fuzzinessOf(term string) int {
+	fuzziness := 0
+	if n := len([]rune(term)); n >= 8 {
+		fuzziness = 2
+	} else if n >= 4 {
+		fuzziness = 1
+	}
+	if fuzziness > i.fuzziness {
+		fuzziness = i.fuzziness
+	}
+	return fuzziness
+}
+
+// record rebuilds a book or a blog from the stored fields of a hit
+func record(resource string, id uint, fields map[string]any) any {
+	text := func(name string) string {
+		value, _ := fields[name].(string)
+		return value
+	}
+	date := func(name string) time.Time {
+		parsed, _ := time.Parse(time.RFC3339, text(name))
+		return parsed
+	}
+	switch resource {
+	case "books":
+		book := models.Book{ISBN: text("isbn"), Title: text("title"), Author: text("author"), Publisher: text("publisher")}
+		book.ID, book.CreatedAt, book.UpdatedAt = id, date("created_at"), date("updated_at")
+		return book
+	case "blogs":
+		userID, _ := fields["user_id"].(float64)
+		blog := models.Blog{Title: text("title"), Content: text("content"), UserID: uint(userID)}
+		blog.ID, blog.CreatedAt, blog.UpdatedAt = id, date("created_at"), date("updated_at")
+		return blog
+	}
+	return nil
+}
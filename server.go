@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/configs"
+	"github.com/rizghz/api/helpers"
+	"google.golang.org/grpc"
+	"gorm.io/gorm"
+)
+
+func serve(e *echo.Echo, conf *configs.ServerConfig, rpc *grpc.Server, rpcAddr string, db *gorm.DB, draining func(), flush func(context.Context) error) error {
+	server := e.Server
+	server.Addr = conf.Address
+	server.ReadHeaderTimeout = conf.ReadHeaderTimeout
+	server.ReadTimeout = conf.ReadTimeout
+	server.WriteTimeout = conf.WriteTimeout
+	server.IdleTimeout = conf.IdleTimeout
+	server.MaxHeaderBytes = conf.MaxHeaderBytes
+
+	if conf.TLS() {
+		var cert tls.Certificate
+		var err error
+		if conf.TLSCertFile != "" {
+			cert, err = tls.LoadX509KeyPair(conf.TLSCertFile, conf.TLSKeyFile)
+		} else {
+			log.Println("[warn]: serving with a self-signed certificate, do not use in production")
+			cert, err = helpers.SelfSignedCertificate("localhost", "127.0.0.1", "::1")
+		}
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 2)
+	go func() {
+		errs <- e.StartServer(server)
+	}()
+	if rpc != nil {
+		lis, err := net.Listen("tcp", rpcAddr)
+		if err != nil {
+			return err
+		}
+		log.Printf("grpc server started on %s", lis.Addr())
+		go func() {
+			errs <- rpc.Serve(lis)
+		}()
+	}
+	select {
+	case err := <-errs:
+		if !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, grpc.ErrServerStopped) {
+			return err
+		}
+	case <-ctx.Done():
+	}
+
+	// fail readiness first so load balancers stop routing new traffic
+	log.Println("shutting down http server")
+	draining()
+	time.Sleep(conf.ShutdownDelay)
+
+	// stop accepting connections and drain in-flight requests
+	shutdown, cancel := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+	defer cancel()
+	err := e.Shutdown(shutdown)
+	if rpc != nil {
+		stopGRPC(shutdown, rpc)
+	}
+	// export the spans of the drained requests before exiting
+	err = errors.Join(err, flush(shutdown))
+	if sqlDB, dbErr := db.DB(); dbErr == nil {
+		err = errors.Join(err, sqlDB.Close())
+	}
+	return err
+}
+
+// stopGRPC waits for in-flight calls until ctx expires, then cuts them off
+func stopGRPC(ctx context.Context, rpc *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		rpc.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		rpc.Stop()
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rizghz/api/configs"
+	"github.com/rizghz/api/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServe(t *testing.T) {
+	db, err := models.Init(&configs.DatabaseConfig{Driver: "sqlite", DSN: "file:serve?mode=memory&cache=shared"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := lis.Addr().String()
+	lis.Close()
+
+	t.Run("Valid Serve Graceful Shutdown", func(t *testing.T) {
+		var drained atomic.Bool
+		started, queried := make(chan struct{}), make(chan error, 1)
+		e := echo.New()
+		e.HideBanner, e.HidePort = true, true
+		e.GET("/slow", func(ctx echo.Context) error {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+			// the database must still be open for requests in flight
+			queried <- db.WithContext(ctx.Request().Context()).Exec("SELECT 1").Error
+			return ctx.NoContent(http.StatusOK)
+		})
+
+		served := make(chan error, 1)
+		go func() {
+			served <- serve(e, &configs.ServerConfig{Address: address, ShutdownTimeout: 5 * time.Second}, nil, "", db,
+				func() { drained.Store(true) }, func(context.Context) error { return nil })
+		}()
+
+		responses := make(chan int, 1)
+		go func() {
+			for {
+				res, err := http.Get("http://" + address + "/slow")
+				if err == nil {
+					res.Body.Close()
+					responses <- res.StatusCode
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("request never reached the handler")
+		}
+		syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+
+		assert.Equal(t, http.StatusOK, <-responses)
+		assert.NoError(t, <-queried)
+		assert.NoError(t, <-served)
+		assert.True(t, drained.Load())
+		if sqlDB, err := db.DB(); assert.NoError(t, err) {
+			assert.Error(t, sqlDB.Ping(), "the database is closed once drained")
+		}
+	})
+}
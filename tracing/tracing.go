@@ -0,0 +1,115 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/rizghz/api/configs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const name = "github.com/rizghz/api"
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// Init installs the global tracer provider and W3C trace context
+// propagator, returning a function that flushes pending spans.
+func Init(ctx context.Context, config *configs.TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	if config.Exporter == "none" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, closer, err := newExporter(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(config.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			closer.Close()
+		}
+		return err
+	}, nil
+}
+
+func newExporter(ctx context.Context, config *configs.TracingConfig) (sdktrace.SpanExporter, io.Closer, error) {
+	switch config.Exporter {
+	case "stdout":
+		exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		return exporter, nil, err
+	case "file":
+		file, err := os.OpenFile(config.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("[err]: trace file could not be opened: %v", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return exporter, file, nil
+	}
+
+	options, err := otlpOptions(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	return exporter, nil, err
+}
+
+// otlpOptions accepts the endpoint either as host:port or as a full URL
+func otlpOptions(config *configs.TracingConfig) ([]otlptracehttp.Option, error) {
+	options := []otlptracehttp.Option{}
+	endpoint := config.Endpoint
+	if strings.Contains(endpoint, "://") {
+		parsed, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("[err]: OTEL_EXPORTER_OTLP_ENDPOINT is not a valid url: %v", err)
+		}
+		endpoint = parsed.Host
+		if parsed.Scheme == "http" {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		if path := strings.TrimSuffix(parsed.Path, "/"); path != "" {
+			options = append(options, otlptracehttp.WithURLPath(path+"/v1/traces"))
+		}
+	}
+	options = append(options, otlptracehttp.WithEndpoint(endpoint))
+	if config.Insecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	if len(config.Headers) > 0 {
+		headers := map[string]string{}
+		for _, header := range config.Headers {
+			if key, val, found := strings.Cut(header, "="); found {
+				headers[strings.TrimSpace(key)] = strings.TrimSpace(val)
+			}
+		}
+		options = append(options, otlptracehttp.WithHeaders(headers))
+	}
+	return options, nil
+}